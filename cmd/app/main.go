@@ -12,7 +12,7 @@ func main() {
 
 	if err != nil {
 		log.Fatalf("This config file is not loaded properly %v\n", err)
-		log.Printf("The error message is:", err)
+		log.Println("The error message is:", err)
 	}
 
 	api.StartServer(cfg)
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"ecommerce-app/pkg/money"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +18,20 @@ type AppConfig struct {
 	TwilioAccountSid      string
 	TwilioAuthToken       string
 	TwilioFromPhoneNumber string
+	StorageDriver         string
+	StorageLocalDir       string
+	MediaBaseUrl          string
+	S3Endpoint            string
+	S3Bucket              string
+	S3Region              string
+	S3AccessKey           string
+	S3SecretKey           string
+	ShippingFee           money.Money // flat fee charged once per seller in an order, in Currency
+	TaxRate               float64     // percent charged on the discounted subtotal
+	Currency              string      // default ISO code, for buyers and sellers who did not pick one
+	RatesFile             string      // JSON exchange rate table, see money.LoadRates
+	PaymentProvider       string
+	WebhookSecrets        map[string]string // webhook signing secret per payment provider
 }
 
 // function to read environment variables and return application struct
@@ -65,6 +82,31 @@ func SetupEnv() (cfg AppConfig, err error) {
 	// 	return AppConfig{}, errors.New("env variables not found")
 	// }
 
+	taxRate, _ := strconv.ParseFloat(os.Getenv("TAX_RATE"), 64)
+	// PAYMENT_WEBHOOK_SECRET_FAKE=... is the secret of the "fake" provider
+	webhookSecrets := map[string]string{}
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		if provider, ok := strings.CutPrefix(key, "PAYMENT_WEBHOOK_SECRET_"); ok && len(value) > 0 {
+			webhookSecrets[strings.ToLower(provider)] = value
+		}
+	}
+	currency := os.Getenv("CURRENCY")
+	if len(currency) == 0 {
+		currency = "USD"
+	}
+	currency, err = money.Normalize(currency)
+	if err != nil {
+		return AppConfig{}, fmt.Errorf("CURRENCY %q is not supported", os.Getenv("CURRENCY"))
+	}
+	// shipping is free when no fee is configured, the fee is a decimal amount such as 4.99
+	shippingFee := money.Zero(currency)
+	if fee := os.Getenv("SHIPPING_FEE"); len(fee) > 0 {
+		if shippingFee, err = money.Parse(fee, currency); err != nil || shippingFee.IsNegative() {
+			return AppConfig{}, fmt.Errorf("SHIPPING_FEE %q is not a valid %s amount", fee, currency)
+		}
+	}
+
 	return AppConfig{
 		// ServerPort: httpPort, 
 		Dsn: Dsn, 
@@ -72,5 +114,19 @@ func SetupEnv() (cfg AppConfig, err error) {
 		TwilioAccountSid:      os.Getenv("TWILIO_ACCOUNT_SID"),
 		TwilioAuthToken:       os.Getenv("TWILIO_AUTH_TOKEN"),
 		TwilioFromPhoneNumber: os.Getenv("TWILIO_FROM_PHONE_NUMBER"),
+		StorageDriver:         os.Getenv("STORAGE_DRIVER"),
+		StorageLocalDir:       os.Getenv("STORAGE_LOCAL_DIR"),
+		MediaBaseUrl:          os.Getenv("MEDIA_BASE_URL"),
+		S3Endpoint:            os.Getenv("S3_ENDPOINT"),
+		S3Bucket:              os.Getenv("S3_BUCKET"),
+		S3Region:              os.Getenv("S3_REGION"),
+		S3AccessKey:           os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:           os.Getenv("S3_SECRET_KEY"),
+		ShippingFee:           shippingFee,
+		TaxRate:               taxRate,
+		Currency:              currency,
+		RatesFile:             os.Getenv("EXCHANGE_RATES_FILE"),
+		PaymentProvider:       os.Getenv("PAYMENT_PROVIDER"),
+		WebhookSecrets:        webhookSecrets,
 	}, nil
 }
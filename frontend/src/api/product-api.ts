@@ -72,7 +72,7 @@ export const FetchSellerOrders = async () => {
 export const FetchSellerOrderById = async (id: number) => {
   const auth = axiosAuth();
   try {
-    const response = await auth.get(`${PRODUCT_URL}/seller/orders/${id}`);
+    const response = await auth.get(`${PRODUCT_URL}/seller/order-items/${id}`);
     return response.data;
   } catch (error) {
     console.log(error);
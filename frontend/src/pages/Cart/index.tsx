@@ -18,6 +18,7 @@ import ProductPlaceholder from "../../images/product_placeholder.jpg";
 import { CollectPaymentApi } from "../../api/payment-api";
 import { MakePayment } from "../Payment";
 import { TabContext, TabList, TabPanel } from "@mui/lab";
+import { formatMoney, moneyValue } from "../../utils/helpers";
 
 interface CartProps {}
 
@@ -69,7 +70,7 @@ const CartPage: React.FC<CartProps> = ({}) => {
       const items = data.cartItems as CartModel[];
       if (Array.isArray(items)) {
         const totalAmount = items.reduce(
-          (sum, item) => sum + Number(item.price) * item.item_qty,
+          (sum, item) => sum + moneyValue(item.price) * item.item_qty,
           0
         );
         setProductPrice(totalAmount);
@@ -160,7 +161,7 @@ const CartPage: React.FC<CartProps> = ({}) => {
                 fontWeight: "600",
               }}
             >
-              ${Number(item.price).toFixed(2)}
+              {formatMoney(item.price)}
             </p>
           </div>
         </RowDiv>
@@ -14,6 +14,7 @@ import { FetchOrderItemsApi } from "../../api/product-api";
 import { useAppSelector } from "../../state/hooks";
 import { Container } from "../../utils/globalstyled";
 import { OrderModel } from "../../types/models/order-model";
+import { formatMoney } from "../../utils/helpers";
 
 interface OrderProps {}
 
@@ -107,7 +108,7 @@ const OrderPage: React.FC<OrderProps> = ({}) => {
                   fontWeight: "600",
                 }}
               >
-                {`Amount:   $${Number(item.price / 100).toFixed(2)}`}
+                {`Amount:   ${formatMoney(item.price)}`}
               </p>
             </div>
           </RowDiv>
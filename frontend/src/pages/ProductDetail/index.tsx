@@ -8,6 +8,7 @@ import { useAppSelector } from "../../state/hooks";
 import { ColDiv, RowDiv } from "../../components/Misc/misc.styled";
 import ProductPlaceholder from "../../images/place_holder.jpg";
 import { AppCSS, TapButton } from "../../components";
+import { formatMoney } from "../../utils/helpers";
 
 export const ProductDetails = () => {
   let { id } = useParams();
@@ -51,7 +52,7 @@ export const ProductDetails = () => {
       <ColDiv style={{ padding: 20, background: "#fff", marginRight: 50 }}>
         <p style={{ fontSize: 30 }}>{currentProduct.name}</p>
         <p style={{ fontSize: 20 }}>{currentProduct.description}</p>
-        <p>{currentProduct.price}</p>
+        <p>{formatMoney(currentProduct.price)}</p>
         <TapButton
           onTap={() => {}}
           title="Buy"
@@ -8,7 +8,9 @@ import {
   SelectChangeEvent,
 } from "@mui/material";
 import { useEffect, useState } from "react";
-import { IMG_URL } from "../../utils/helpers";
+import { IMG_URL, toMoney } from "../../utils/helpers";
+import { CURRENCY } from "../../utils/AppConst";
+import { useAppSelector } from "../../state/hooks";
 import { FileUpload, PriceInput, TxtInput } from "../../components/Input";
 import placeHolder from "../../images/product_placeholder.jpg";
 import { ColDiv, RowDiv } from "../../components/Misc/misc.styled";
@@ -32,6 +34,7 @@ export const AddProductPopup: React.FC<AddProductProps> = ({
   onClose,
 }) => {
   const dispatch = useDispatch();
+  const profile = useAppSelector((state) => state.userReducer.userProfile);
 
   const [name, setName] = useState("");
   const [description, setDescription] = useState("");
@@ -77,7 +80,7 @@ export const AddProductPopup: React.FC<AddProductProps> = ({
       name,
       description,
       image_url: image,
-      price: +price,
+      price: toMoney(price, profile.currency || CURRENCY),
       stock: +stock,
     };
 
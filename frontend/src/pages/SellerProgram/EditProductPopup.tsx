@@ -8,7 +8,8 @@ import {
   SelectChangeEvent,
 } from "@mui/material";
 import { useEffect, useState } from "react";
-import { IMG_URL } from "../../utils/helpers";
+import { IMG_URL, moneyValue, toMoney } from "../../utils/helpers";
+import { CURRENCY } from "../../utils/AppConst";
 import { FileUpload, PriceInput, TxtInput } from "../../components/Input";
 import placeHolder from "../../images/product_placeholder.jpg";
 import { ColDiv, RowDiv } from "../../components/Misc/misc.styled";
@@ -51,7 +52,7 @@ export const EditProductPopup: React.FC<EditProductProps> = ({
       setName(product.name);
       setDescription(product.description);
       setCatId(product.category_id);
-      setPrice(product.price);
+      setPrice(moneyValue(product.price));
       setStock(product.stock);
       setImage(product.image_url);
     }
@@ -77,7 +78,7 @@ export const EditProductPopup: React.FC<EditProductProps> = ({
       name: name,
       description: description,
       image_url: image,
-      price: +price,
+      price: toMoney(price, product?.price?.currency || CURRENCY),
       stock: +stock,
     };
 
@@ -7,6 +7,7 @@ import { Container } from "@mui/material";
 import { AppCSS, Lbl, Spacer, TapButton, TxtInput } from "../../components";
 import { ColDiv, RowDiv } from "../../components/Misc/misc.styled";
 import moment from "moment";
+import { formatMoney } from "../../utils/helpers";
 
 interface OrderTableProps {
   orders: OrderModel[];
@@ -93,7 +94,7 @@ export const OrderTable: React.FC<OrderTableProps> = ({ orders }) => {
                       >{`${row.name}`}</p>
                     </td>
 
-                    <td>{row.price}</td>
+                    <td>{formatMoney(row.price)}</td>
                     <td>
                       <TapButton
                         title="View"
@@ -22,6 +22,7 @@ import { FetchSellerOrderById } from "../../api/product-api";
 import { useAppSelector } from "../../state/hooks";
 import { setSellerOrder } from "../../state/reducers/productSlice";
 import { SellerOrderModel } from "../../types/models/order-model";
+import { formatMoney } from "../../utils/helpers";
 
 interface DashboardProps {}
 
@@ -220,10 +221,7 @@ export const SellerOrderDetails: React.FC<DashboardProps> = ({}) => {
   };
 
   const productPrice = () => {
-    if (sellerOrderInfo.price) {
-      return sellerOrderInfo?.price.toFixed(2);
-    }
-    return 0;
+    return formatMoney(sellerOrderInfo?.price);
   };
 
   const OrderDetails = () => {
@@ -289,7 +287,7 @@ export const SellerOrderDetails: React.FC<DashboardProps> = ({}) => {
                   />
                   <TxtInput
                     disable={true}
-                    value={`$ ${productPrice()}`}
+                    value={productPrice()}
                     placeholder="Damaged By"
                     onChange={() => {}}
                   />
@@ -46,6 +46,7 @@ import {
 import { EditProductPopup } from "./EditProductPopup";
 import { OrderTable } from "./OrdersTable";
 import { OrderModel } from "../../types/models/order-model";
+import { formatMoney } from "../../utils/helpers";
 
 interface ProductViewProps {}
 
@@ -131,7 +132,7 @@ const ProductView: React.FC<ProductViewProps> = ({}) => {
               <p style={{ margin: 0, fontWeight: "600" }}>{title}</p>
               <p>{item.id}</p>
               <p style={{ margin: 0, fontWeight: "300" }}>
-                {`$${item.price.toFixed(2)}`}
+                {formatMoney(item.price)}
               </p>
             </ProductPriceDiv>
           </ProductCard>
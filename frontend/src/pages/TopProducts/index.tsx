@@ -4,6 +4,7 @@ import { GridListTile } from "@material-ui/core";
 import { ProductModel } from "../../types";
 import React from "react";
 import placeholderImage from "../../images/place_holder.jpg";
+import { formatMoney } from "../../utils/helpers";
 
 interface CategoryProps {
   products: ProductModel[];
@@ -48,7 +49,7 @@ export const TopPrducts: React.FC<CategoryProps> = ({ products }) => {
               }}
             >
               <p>{item.name}</p>
-              <p>${item.price}</p>
+              <p>{formatMoney(item.price)}</p>
             </div>
           </a>
         </GridListTile>
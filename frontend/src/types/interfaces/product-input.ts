@@ -1,8 +1,10 @@
+import { MoneyModel } from "../models/money-model";
+
 export interface CreateProductInput {
   name: string;
   description: string;
   category_id: number;
   image_url: string;
-  price: number;
+  price: MoneyModel;
   stock: number;
 }
@@ -1,3 +1,4 @@
 export * from "./user-model";
 export * from "./product-model";
 export * from "./category-model";
+export * from "./money-model";
@@ -0,0 +1,5 @@
+// an amount in minor units of its currency, e.g. { amount: 1999, currency: "USD" } is $19.99
+export interface MoneyModel {
+  amount: number;
+  currency: string;
+}
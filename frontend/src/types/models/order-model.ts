@@ -1,3 +1,5 @@
+import { MoneyModel } from "./money-model";
+
 export interface OrderModel {
   id: number;
   order_id: number;
@@ -5,7 +7,7 @@ export interface OrderModel {
   name: string;
   image_url: string;
   seller_id: number;
-  price: number;
+  price: MoneyModel;
   qty: number;
   CreatedAt: string;
   UpdatedAt: string;
@@ -19,7 +21,7 @@ export interface SellerOrderModel {
   product_id: number;
   name: string;
   image_url: string;
-  price: number;
+  price: MoneyModel;
   qty: number;
   customer_name: string;
   customer_email: string;
@@ -1,9 +1,11 @@
+import { MoneyModel } from "./money-model";
+
 export interface CartModel {
   item_id: string;
   cart_id: string;
   product_id: string;
   name: string;
-  price: string;
+  price: MoneyModel;
   image_url: string;
   item_qty: number;
 }
@@ -14,7 +16,7 @@ export interface ProductModel {
   description: string;
   category_id: string;
   image_url: string;
-  price: number;
+  price: MoneyModel;
   stock: number;
   availability: boolean;
 }
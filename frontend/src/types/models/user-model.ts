@@ -8,6 +8,7 @@ export interface UserModel {
   phone: string;
   createdAt: string;
   user_type: string;
+  currency: string; // sellers price products in it, empty for the default currency
 }
 
 export interface RegisterModel {
@@ -2,3 +2,4 @@ export const BASE_URL = process.env.REACT_APP_BASE_URL; // Your local user servi
 export const PRODUCT_URL = process.env.REACT_APP_PRODUCT_URL;
 export const TRANSACTION_URL = process.env.REACT_APP_TRANSACTION_URL;
 export const S3URL = process.env.REACT_APP_S3_URL;
+export const CURRENCY = process.env.REACT_APP_CURRENCY || "USD"; // the API's default currency
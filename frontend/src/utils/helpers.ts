@@ -1,3 +1,4 @@
+import { MoneyModel } from "../types/models/money-model";
 import { S3URL } from "./AppConst";
 
 export const IMG_URL = (imageId?: string) => {
@@ -6,3 +7,33 @@ export const IMG_URL = (imageId?: string) => {
   }
   return false;
 };
+
+const currencyDigits = (currency: string) =>
+  new Intl.NumberFormat("en", { style: "currency", currency }).resolvedOptions()
+    .maximumFractionDigits;
+
+// the decimal value of the amount, e.g. 19.99 for { amount: 1999, currency: "USD" }
+export const moneyValue = (money?: MoneyModel | null) => {
+  if (money) {
+    return money.amount / 10 ** currencyDigits(money.currency);
+  }
+  return 0;
+};
+
+export const formatMoney = (money?: MoneyModel | null) => {
+  if (money) {
+    return new Intl.NumberFormat(undefined, {
+      style: "currency",
+      currency: money.currency,
+    }).format(moneyValue(money));
+  }
+  return "";
+};
+
+// the amount the API expects for a decimal price such as 19.99
+export const toMoney = (value: number | string, currency: string): MoneyModel => {
+  return {
+    amount: Math.round(+value * 10 ** currencyDigits(currency)),
+    currency,
+  };
+};
@@ -1,13 +1,20 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
 	"ecommerce-app/internal/api/rest"
 	"ecommerce-app/internal/dto"
 	"ecommerce-app/internal/repository"
 	"ecommerce-app/internal/service"
-	"strconv"
+	"ecommerce-app/pkg/storage"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
 )
 
 type catalogHandler struct {
@@ -19,9 +26,12 @@ func SetupCatalogRoutes(rh *rest.RestHandler) {
 
 	// Create an instance of the catalog service and inject to the handler
 	svc := service.CatalogService{
-		Repo:   repository.NewCatalogRepository(rh.DB),
-		Auth:   rh.Auth,
-		Config: rh.Config,
+		Repo:    repository.NewCatalogRepository(rh.DB),
+		URepo:   repository.NewUserRepository(rh.DB),
+		Auth:    rh.Auth,
+		Config:  rh.Config,
+		Storage: storage.NewStorage(rh.Config),
+		Rates:   rh.Rates,
 	}
 
 	handler := catalogHandler{
@@ -30,9 +40,15 @@ func SetupCatalogRoutes(rh *rest.RestHandler) {
 
 	// Public Catalog Endpoints
 	app.Get("/products", handler.GetProducts)
+	app.Get("/products/search", handler.SearchProducts)
 	app.Get("/products/:id", handler.GetProduct)
 	app.Get("categories", handler.GetCategories)
+	app.Get("/categories/tree", handler.GetCategoryTree)
 	app.Get("/categories/:id", handler.GetCategoryById)
+	app.Get("/categories/:id/breadcrumbs", handler.GetCategoryBreadcrumbs)
+
+	// Uploaded images
+	app.Get("/media/*", handler.ServeMedia)
 
 	// Private Catalog Endpoints
 	selRoutes := app.Group("/seller", rh.Auth.AuthorizeSeller)
@@ -40,14 +56,35 @@ func SetupCatalogRoutes(rh *rest.RestHandler) {
 	selRoutes.Post("/categories", handler.CreateCategories)
 	selRoutes.Patch("/categories/:id", handler.EditCategory)
 	selRoutes.Delete("/categories/:id", handler.DeleteCategory)
+	selRoutes.Post("/categories/:id/image", handler.UploadCategoryImage)
 
 	// Products
 	selRoutes.Post("/products", handler.CreateProducts)
-	selRoutes.Get("/products", handler.GetProducts)
-	selRoutes.Get("/products/:id", handler.GetProduct)
+	selRoutes.Get("/products", handler.GetSellerProducts)
+	selRoutes.Post("/products/import", handler.ImportProducts)
+	selRoutes.Get("/products/export", handler.ExportProducts)
+	selRoutes.Get("/products/:id", handler.GetSellerProduct)
 	selRoutes.Put("/products/:id", handler.EditProducts)
 	selRoutes.Patch("/products/:id", handler.UpdateStock) // update stock
 	selRoutes.Delete("/products/:id", handler.DeleteProduct)
+
+	// Product variants
+	selRoutes.Get("/products/:id/variants", handler.GetVariants)
+	selRoutes.Post("/products/:id/variants", handler.CreateVariant)
+	selRoutes.Put("/products/:id/variants/:variantId", handler.EditVariant)
+	selRoutes.Patch("/products/:id/variants/:variantId", handler.UpdateVariantStock) // update stock
+	selRoutes.Delete("/products/:id/variants/:variantId", handler.DeleteVariant)
+	selRoutes.Post("/products/:id/options", handler.CreateOption)
+	selRoutes.Delete("/products/:id/options/:optionId", handler.DeleteOption)
+
+	// Product images
+	selRoutes.Post("/products/:id/images", handler.UploadProductImage)
+	selRoutes.Put("/products/:id/images/order", handler.ReorderProductImages)
+	selRoutes.Delete("/products/:id/images/:imageId", handler.DeleteProductImage)
+
+	// Stock ledger
+	selRoutes.Get("/products/:id/stock-history", handler.GetStockHistory)
+	selRoutes.Get("/products/:id/stock-reconciliation", handler.ReconcileStock)
 }
 func (h *catalogHandler) GetCategories(ctx *fiber.Ctx) error {
 	cats, err := h.svc.GetCategories()
@@ -57,8 +94,31 @@ func (h *catalogHandler) GetCategories(ctx *fiber.Ctx) error {
 	return rest.SuccessResponse(ctx, "categories", cats)
 }
 
+func (h *catalogHandler) GetCategoryTree(ctx *fiber.Ctx) error {
+	tree, err := h.svc.GetCategoryTree()
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "category tree", tree)
+}
+
+func (h *catalogHandler) GetCategoryBreadcrumbs(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	crumbs, err := h.svc.GetCategoryBreadcrumbs(id)
+	if err != nil {
+		return categoryError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "category breadcrumbs", crumbs)
+}
+
 func (h *catalogHandler) GetCategoryById(ctx *fiber.Ctx) error {
-	id, _ := strconv.Atoi(ctx.Params("id"))
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
 	cat, err := h.svc.GetCategory(id)
 	if err != nil {
 		return rest.ErrorMessage(ctx, 404, err)
@@ -74,32 +134,38 @@ func (h *catalogHandler) CreateCategories(ctx *fiber.Ctx) error {
 	}
 	err = h.svc.CreateCategory(req)
 	if err != nil {
-		return rest.InternalError(ctx, err)
+		return categoryError(ctx, err)
 	}
 	return rest.SuccessResponse(ctx, "category created successfully", nil)
 }
 
 func (h *catalogHandler) EditCategory(ctx *fiber.Ctx) error {
-	id, _ := strconv.Atoi(ctx.Params("id"))
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
 
 	req := dto.CreateCategoryRequest{}
-	err := ctx.BodyParser(&req)
+	err = ctx.BodyParser(&req)
 	if err != nil {
 		return rest.BadRequestError(ctx, "update category request is not valid")
 	}
 	updatedCategory, err := h.svc.EditCategory(id, req)
 
 	if err != nil {
-		return rest.InternalError(ctx, err)
+		return categoryError(ctx, err)
 	}
 	return rest.SuccessResponse(ctx, "edit category endpoint", updatedCategory)
 }
 
 func (h *catalogHandler) DeleteCategory(ctx *fiber.Ctx) error {
-	id, _ := strconv.Atoi(ctx.Params("id"))
-	err := h.svc.DeleteCategory(id)
+	id, err := rest.IdParam(ctx, "id")
 	if err != nil {
-		return rest.InternalError(ctx, err)
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	err = h.svc.DeleteCategory(id)
+	if err != nil {
+		return categoryError(ctx, err)
 	}
 	return rest.SuccessResponse(ctx, "delete category endpoint", nil)
 }
@@ -115,33 +181,487 @@ func (h *catalogHandler) CreateProducts(ctx *fiber.Ctx) error {
 	}
 	err = h.svc.CreateProduct(req, user)
 	if err != nil {
-		return rest.InternalError(ctx, err)
+		return productError(ctx, err)
 	}
 	return rest.SuccessResponse(ctx, "product created successfully", nil)
 }
 
 func (h *catalogHandler) EditProducts(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
 
-	return rest.SuccessResponse(ctx, "edit product endpoint", nil)
+	req := dto.CreateProductRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "edit product request is not valid")
+	}
+	product, err := h.svc.EditProduct(id, req, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product updated successfully", product)
 }
 
 func (h *catalogHandler) GetProducts(ctx *fiber.Ctx) error {
-	products, err := h.svc.GetProducts()
+	query := dto.ProductListQuery{}
+	err := ctx.QueryParser(&query)
 	if err != nil {
-		return rest.ErrorMessage(ctx, 404, err)
+		return rest.BadRequestError(ctx, "product listing parameters are not valid")
+	}
+	products, meta, err := h.svc.ListProducts(query)
+	if err != nil {
+		return productError(ctx, err)
 	}
-	return rest.SuccessResponse(ctx, "categories", products)
+	return rest.PaginatedResponse(ctx, "products", products, meta)
+}
+
+func (h *catalogHandler) SearchProducts(ctx *fiber.Ctx) error {
+	query := dto.ProductSearchQuery{}
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return rest.BadRequestError(ctx, "product search parameters are not valid")
+	}
+	hits, meta, err := h.svc.SearchProducts(query)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.PaginatedResponse(ctx, "search results", hits, meta)
 }
 
 func (h *catalogHandler) GetProduct(ctx *fiber.Ctx) error {
-	return rest.SuccessResponse(ctx, "get product by ID", nil)
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	product, err := h.svc.GetProductById(id)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product", product)
+}
+
+func (h *catalogHandler) GetSellerProducts(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+	products, err := h.svc.GetSellerProducts(user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "seller products", products)
+}
+
+// ImportProducts accepts the CSV either as a multipart "file" field or as the raw request body
+func (h *catalogHandler) ImportProducts(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	var body io.Reader = bytes.NewReader(ctx.Body())
+	if fh, err := ctx.FormFile("file"); err == nil {
+		file, err := fh.Open()
+		if err != nil {
+			return rest.BadRequestError(ctx, "uploaded file cannot be read")
+		}
+		defer file.Close()
+		body = file
+	}
+
+	result, err := h.svc.ImportProducts(body, user)
+	if errors.Is(err, service.ErrInvalidCsv) {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "products imported", result)
+}
+
+// ExportProducts streams the seller's catalogue as CSV
+func (h *catalogHandler) ExportProducts(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	ctx.Set(fiber.HeaderContentType, "text/csv")
+	ctx.Set(fiber.HeaderContentDisposition, `attachment; filename="products.csv"`)
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		err := h.svc.ExportProducts(w, user)
+		if err != nil {
+			log.Printf("error on exporting products %v", err)
+		}
+	})
+	return nil
+}
+
+func (h *catalogHandler) GetSellerProduct(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+	product, err := h.svc.GetSellerProduct(id, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product", product)
 }
 
 func (h *catalogHandler) UpdateStock(ctx *fiber.Ctx) error {
-	return rest.SuccessResponse(ctx, "update stock endpoint", nil)
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.UpdateStockRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "update stock request is not valid")
+	}
+	product, err := h.svc.UpdateProductStock(id, req, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "stock updated successfully", product)
 }
 
 func (h *catalogHandler) DeleteProduct(ctx *fiber.Ctx) error {
-	return rest.SuccessResponse(ctx, "delete product endpoint", nil)
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.DeleteProduct(id, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product deleted successfully", nil)
+}
+
+func (h *catalogHandler) GetVariants(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	variants, err := h.svc.GetProductVariants(id, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product variants", variants)
+}
+
+func (h *catalogHandler) CreateVariant(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreateVariantRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "create variant request is not valid")
+	}
+	variant, err := h.svc.CreateVariant(id, req, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "variant created successfully", variant)
+}
+
+func (h *catalogHandler) EditVariant(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	variantId, err := rest.IdParam(ctx, "variantId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreateVariantRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "edit variant request is not valid")
+	}
+	variant, err := h.svc.EditVariant(id, variantId, req, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "variant updated successfully", variant)
+}
+
+func (h *catalogHandler) UpdateVariantStock(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	variantId, err := rest.IdParam(ctx, "variantId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.UpdateStockRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "update stock request is not valid")
+	}
+	variant, err := h.svc.UpdateVariantStock(id, variantId, req, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "stock updated successfully", variant)
+}
+
+func (h *catalogHandler) DeleteVariant(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	variantId, err := rest.IdParam(ctx, "variantId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.DeleteVariant(id, variantId, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "variant deleted successfully", nil)
+}
+
+func (h *catalogHandler) CreateOption(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreateOptionRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "create option request is not valid")
+	}
+	option, err := h.svc.CreateOption(id, req, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "option created successfully", option)
+}
+
+func (h *catalogHandler) DeleteOption(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	optionId, err := rest.IdParam(ctx, "optionId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.DeleteOption(id, optionId, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "option deleted successfully", nil)
+}
+
+func (h *catalogHandler) GetStockHistory(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	query := dto.PageQuery{}
+	err = ctx.QueryParser(&query)
+	if err != nil {
+		return rest.BadRequestError(ctx, "pagination parameters are not valid")
+	}
+	movements, meta, err := h.svc.GetStockHistory(id, query, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.PaginatedResponse(ctx, "stock history", movements, meta)
+}
+
+func (h *catalogHandler) ReconcileStock(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	drifts, err := h.svc.ReconcileStock(id, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "stock reconciliation", drifts)
+}
+
+func (h *catalogHandler) UploadProductImage(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	data, err := readImageUpload(ctx)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	img, err := h.svc.UploadProductImage(id, data, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "image uploaded successfully", img)
+}
+
+func (h *catalogHandler) ReorderProductImages(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.ReorderImagesRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "reorder images request is not valid")
+	}
+	images, err := h.svc.ReorderProductImages(id, req.ImageIds, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "images reordered successfully", images)
+}
+
+func (h *catalogHandler) DeleteProductImage(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	imageId, err := rest.IdParam(ctx, "imageId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.DeleteProductImage(id, imageId, user)
+	if err != nil {
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "image deleted successfully", nil)
+}
+
+func (h *catalogHandler) UploadCategoryImage(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+
+	data, err := readImageUpload(ctx)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	category, err := h.svc.UploadCategoryImage(id, data)
+	if err != nil {
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			return categoryError(ctx, err)
+		}
+		return productError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "image uploaded successfully", category)
+}
+
+// ServeMedia serves uploaded files. Keys are never reused, so responses can be cached forever.
+func (h *catalogHandler) ServeMedia(ctx *fiber.Ctx) error {
+	key := ctx.Params("*")
+	etag := fmt.Sprintf("%q", key)
+	if ctx.Get(fiber.HeaderIfNoneMatch) == etag {
+		return ctx.SendStatus(http.StatusNotModified)
+	}
+
+	obj, err := h.svc.Storage.Get(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
 
+	ctx.Set(fiber.HeaderContentType, obj.ContentType)
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	ctx.Set(fiber.HeaderETag, etag)
+	return ctx.SendStream(obj.Body, int(obj.Size))
+}
+
+// readImageUpload reads the multipart "image" field, rejecting oversized files before reading them
+func readImageUpload(ctx *fiber.Ctx) ([]byte, error) {
+	fh, err := ctx.FormFile("image")
+	if err != nil {
+		return nil, errors.New("please upload the image in the \"image\" field")
+	}
+	if fh.Size > service.MaxImageSize {
+		return nil, service.ErrImageTooLarge
+	}
+	file, err := fh.Open()
+	if err != nil {
+		return nil, errors.New("uploaded file cannot be read")
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, service.MaxImageSize+1))
+}
+
+// productError maps catalog service errors to their HTTP status
+func productError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, service.ErrVariantNotFound),
+		errors.Is(err, service.ErrImageNotFound),
+		errors.Is(err, service.ErrOptionNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrProductNotOwned):
+		return rest.ErrorMessage(ctx, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrInvalidProduct), errors.Is(err, service.ErrInvalidStock),
+		errors.Is(err, service.ErrInvalidQuery),
+		errors.Is(err, service.ErrInvalidVariant),
+		errors.Is(err, service.ErrPriceCurrency),
+		errors.Is(err, service.ErrUnknownCategory),
+		errors.Is(err, service.ErrInvalidOption),
+		errors.Is(err, service.ErrLastVariant),
+		errors.Is(err, service.ErrStockManagedByVariants),
+		errors.Is(err, service.ErrImageType),
+		errors.Is(err, service.ErrInvalidImageList):
+		return rest.BadRequestError(ctx, err.Error())
+	case errors.Is(err, service.ErrImageTooLarge),
+		errors.Is(err, service.ErrImageDimensions):
+		return rest.ErrorMessage(ctx, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, service.ErrDuplicateSku),
+		errors.Is(err, service.ErrDuplicateExternalSku),
+		errors.Is(err, service.ErrDuplicateVariant),
+		errors.Is(err, service.ErrOptionInUse):
+		return rest.ErrorMessage(ctx, http.StatusConflict, err)
+	default:
+		return rest.InternalError(ctx, err)
+	}
+}
+
+// categoryError maps category service errors to their HTTP status
+func categoryError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCategoryNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrParentNotFound), errors.Is(err, service.ErrCategoryCycle):
+		return rest.BadRequestError(ctx, err.Error())
+	case errors.Is(err, repository.ErrCategoryHasProducts):
+		return rest.ErrorMessage(ctx, http.StatusConflict, err)
+	default:
+		return rest.InternalError(ctx, err)
+	}
 }
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"ecommerce-app/internal/api/rest"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	cartTokenHeader = "X-Cart-Token"
+	cartTokenCookie = "cart_token"
+	cartTokenMaxAge = 30 * 24 * time.Hour
+)
+
+type guestCartHandler struct {
+	svc service.UserService
+}
+
+// SetupGuestCartRoutes lets visitors build a cart before they have an account.
+// The cart is identified by an opaque token sent back in the X-Cart-Token header and a cookie,
+// it is merged into the user's cart on /users/register and /users/login.
+func SetupGuestCartRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	svc := service.UserService{
+		Repo:   repository.NewUserRepository(rh.DB),
+		CRepo:  repository.NewCatalogRepository(rh.DB),
+		PRepo:  repository.NewPromotionRepository(rh.DB),
+		Auth:   rh.Auth,
+		Config: rh.Config,
+		Rates:  rh.Rates,
+	}
+	handler := guestCartHandler{
+		svc: svc,
+	}
+
+	app.Get("/cart", handler.GetCart)
+	app.Post("/cart", handler.AddToCart)
+	app.Patch("/cart/:productId", handler.UpdateCartItem)
+	app.Delete("/cart/:productId", handler.RemoveCartItem)
+	app.Delete("/cart", handler.ClearCart)
+}
+
+// cartToken reads the guest cart token from the header, falling back to the cookie
+func cartToken(ctx *fiber.Ctx) string {
+	if token := ctx.Get(cartTokenHeader); len(token) > 0 {
+		return token
+	}
+	return ctx.Cookies(cartTokenCookie)
+}
+
+func setCartToken(ctx *fiber.Ctx, token string) {
+	ctx.Set(cartTokenHeader, token)
+	ctx.Cookie(&fiber.Cookie{
+		Name:     cartTokenCookie,
+		Value:    token,
+		Expires:  time.Now().Add(cartTokenMaxAge),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// clearCartToken drops the cookie once the guest cart was merged
+func clearCartToken(ctx *fiber.Ctx) {
+	ctx.ClearCookie(cartTokenCookie)
+}
+
+func (h *guestCartHandler) GetCart(ctx *fiber.Ctx) error {
+	token := cartToken(ctx)
+	// visitors pick the currency they see their cart in, e.g. /cart?currency=EUR
+	cart, err := h.svc.GetGuestCartSummary(token, ctx.Query("currency"))
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "get cart",
+		"cart":    cart,
+	})
+}
+
+func (h *guestCartHandler) AddToCart(ctx *fiber.Ctx) error {
+	req := dto.CreateCartRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "please  provide a valid product and qty",
+		})
+	}
+
+	// first item of a new visitor, hand out a token
+	token := cartToken(ctx)
+	if !service.ValidCartToken(token) {
+		var err error
+		token, err = service.NewCartToken()
+		if err != nil {
+			return rest.InternalError(ctx, err)
+		}
+	}
+	setCartToken(ctx, token)
+
+	cartItems, err := h.svc.AddToGuestCart(req, token)
+	if err != nil {
+		return cartError(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(fiber.Map{
+		"message":    "cart created successfully",
+		"cart_token": token,
+		"data":       cartItems,
+	})
+}
+
+func (h *guestCartHandler) UpdateCartItem(ctx *fiber.Ctx) error {
+	productId, err := rest.IdParam(ctx, "productId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+
+	req := dto.UpdateCartRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "please provide a valid qty")
+	}
+	cartItems, err := h.svc.UpdateGuestCartItem(uint(productId), req, cartToken(ctx))
+	if err != nil {
+		return cartError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "cart updated successfully", cartItems)
+}
+
+func (h *guestCartHandler) RemoveCartItem(ctx *fiber.Ctx) error {
+	productId, err := rest.IdParam(ctx, "productId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	variantId := ctx.QueryInt("variant_id")
+
+	cartItems, err := h.svc.RemoveGuestCartItem(uint(productId), uint(variantId), cartToken(ctx))
+	if err != nil {
+		return cartError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product removed from cart", cartItems)
+}
+
+func (h *guestCartHandler) ClearCart(ctx *fiber.Ctx) error {
+	err := h.svc.ClearGuestCart(cartToken(ctx))
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "cart cleared", nil)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"ecommerce-app/internal/api/rest"
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/repository"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	idempotencyTTL    = 24 * time.Hour
+)
+
+// idempotent lets clients retry a request safely by sending an Idempotency-Key header. The first
+// request with a key runs and its response is stored; a retry with the same body gets that response
+// again, reusing the key for a different request is a 409. Keys are scoped to the signed in user,
+// so the middleware goes after Authorize. Requests without the header run as usual.
+func idempotent(rh *rest.RestHandler) fiber.Handler {
+	repo := repository.NewIdempotencyRepository(rh.DB)
+
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get(IdempotencyHeader)
+		if len(key) == 0 {
+			return ctx.Next()
+		}
+		if len(key) > 255 {
+			return rest.BadRequestError(ctx, "idempotency key must be at most 255 characters")
+		}
+
+		hash := sha256.New()
+		hash.Write([]byte(ctx.Method() + " " + ctx.Path() + "\n"))
+		hash.Write(ctx.Body())
+		record := &domain.IdempotencyKey{
+			UserId:      rh.Auth.GetCurrentUser(ctx).ID,
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash.Sum(nil)),
+		}
+		requestHash := record.RequestHash
+
+		reserved, err := repo.ReserveKey(record, idempotencyTTL)
+		if err != nil {
+			return rest.InternalError(ctx, err)
+		}
+		if !reserved {
+			switch {
+			case record.RequestHash != requestHash:
+				return ctx.Status(http.StatusConflict).JSON(fiber.Map{
+					"message": "idempotency key was already used for a different request",
+				})
+			case record.StatusCode == 0:
+				return ctx.Status(http.StatusConflict).JSON(fiber.Map{
+					"message": "a request with this idempotency key is still in progress",
+				})
+			}
+			ctx.Set("Idempotent-Replayed", "true")
+			ctx.Set(fiber.HeaderContentType, record.ContentType)
+			return ctx.Status(record.StatusCode).Send(record.Response)
+		}
+
+		err = ctx.Next()
+		status := ctx.Response().StatusCode()
+		// failed requests did not happen as far as the client is concerned, a retry runs them again
+		if err != nil || status >= http.StatusInternalServerError {
+			if rerr := repo.ReleaseKey(record); rerr != nil {
+				log.Printf("idempotency_err: %v", rerr)
+			}
+			return err
+		}
+		record.StatusCode = status
+		record.ContentType = string(ctx.Response().Header.ContentType())
+		record.Response = append([]byte(nil), ctx.Response().Body()...)
+		if err = repo.SaveResponse(record); err != nil {
+			log.Printf("idempotency_err: %v", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"ecommerce-app/internal/api/rest"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/internal/service"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+)
+
+type orderHandler struct {
+	svc service.OrderService
+}
+
+func SetupOrderRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	svc := service.OrderService{
+		Repo:     repository.NewOrderRepository(rh.DB),
+		URepo:    repository.NewUserRepository(rh.DB),
+		Payments: initializeTransactionService(rh),
+		Auth:     rh.Auth,
+		Config:   rh.Config,
+	}
+	handler := orderHandler{
+		svc: svc,
+	}
+
+	// buyers cancel the whole order or some of its items before they ship
+	app.Post("/users/order/:id/cancel", rh.Auth.Authorize, handler.CancelOrder)
+	// refunds of cancelled items the payment provider failed to issue
+	app.Post("/admin/refunds/:id/retry", rh.Auth.AuthorizeAdmin, handler.RetryRefund)
+
+	// the service decides which transitions each party may make
+	app.Patch("/users/order/:id/status", rh.Auth.Authorize, handler.ChangeOrderStatus)
+	app.Patch("/seller/orders/:id/status", rh.Auth.AuthorizeSeller, handler.ChangeOrderStatus)
+	app.Patch("/admin/orders/:id/status", rh.Auth.AuthorizeAdmin, handler.ChangeOrderStatus)
+	app.Patch("/admin/orders/:id/groups/:groupId/status", rh.Auth.AuthorizeAdmin, handler.ChangeGroupStatus)
+
+	// shipments of the seller's fulfilment group, buyers see them on the order
+	app.Post("/seller/orders/:id/shipments", rh.Auth.AuthorizeSeller, handler.CreateShipment)
+	app.Patch("/seller/shipments/:shipmentId", rh.Auth.AuthorizeSeller, handler.UpdateShipment)
+}
+
+func (h *orderHandler) ChangeOrderStatus(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.OrderStatusRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil || len(req.Status) == 0 {
+		return rest.BadRequestError(ctx, "please provide the new order status")
+	}
+	order, err := h.svc.ChangeOrderStatus(uint(id), req.Status, user, req.Reason)
+	if err != nil {
+		return orderError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "order status updated", order)
+}
+
+func (h *orderHandler) ChangeGroupStatus(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	groupId, err := rest.IdParam(ctx, "groupId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.OrderStatusRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil || len(req.Status) == 0 {
+		return rest.BadRequestError(ctx, "please provide the new status")
+	}
+	order, err := h.svc.ChangeGroupStatus(uint(id), uint(groupId), req.Status, user, req.Reason)
+	if err != nil {
+		return orderError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "fulfilment group status updated", order)
+}
+
+func (h *orderHandler) CancelOrder(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CancelOrderRequest{}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return rest.BadRequestError(ctx, "cancel order request is not valid")
+		}
+	}
+	order, err := h.svc.CancelOrder(uint(id), req, user)
+	if err != nil {
+		return orderError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "order cancelled successfully", order)
+}
+
+func (h *orderHandler) RetryRefund(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	refund, err := h.svc.RetryRefund(uint(id))
+	if err != nil {
+		return orderError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "refund issued", refund)
+}
+
+func (h *orderHandler) CreateShipment(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreateShipmentRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "create shipment request is not valid")
+	}
+	order, err := h.svc.CreateShipment(uint(id), req, user)
+	if err != nil {
+		return orderError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "shipment created successfully", order)
+}
+
+func (h *orderHandler) UpdateShipment(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "shipmentId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.UpdateShipmentRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "update shipment request is not valid")
+	}
+	order, err := h.svc.UpdateShipment(uint(id), req, user)
+	if err != nil {
+		return orderError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "shipment updated successfully", order)
+}
+
+func orderError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound),
+		errors.Is(err, service.ErrNoSellerItems),
+		errors.Is(err, service.ErrShipmentNotFound),
+		errors.Is(err, service.ErrRefundNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrOrderTransitionDenied):
+		return rest.ErrorMessage(ctx, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrInvalidOrderTransition),
+		errors.Is(err, service.ErrShipmentNotAllowed),
+		errors.Is(err, service.ErrShipmentStatus),
+		errors.Is(err, service.ErrOrderShipped),
+		errors.Is(err, service.ErrRefundNotRetryable):
+		return rest.ErrorMessage(ctx, http.StatusConflict, err)
+	case errors.Is(err, service.ErrRefundFailed):
+		return rest.ErrorMessage(ctx, http.StatusBadGateway, err)
+	case errors.Is(err, service.ErrInvalidShipment),
+		errors.Is(err, service.ErrInvalidCancellation):
+		return rest.ErrorMessage(ctx, http.StatusBadRequest, err)
+	default:
+		return rest.InternalError(ctx, err)
+	}
+}
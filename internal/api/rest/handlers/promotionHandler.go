@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"ecommerce-app/internal/api/rest"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/internal/service"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+)
+
+type promotionHandler struct {
+	svc service.PromotionService
+}
+
+func SetupPromotionRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	svc := service.PromotionService{
+		Repo:  repository.NewPromotionRepository(rh.DB),
+		CRepo: repository.NewCatalogRepository(rh.DB),
+		Auth:  rh.Auth,
+		Rates: rh.Rates,
+	}
+	handler := promotionHandler{
+		svc: svc,
+	}
+
+	// Seller endpoints, limited to the seller's own products
+	app.Get("/seller/promotions", rh.Auth.AuthorizeSeller, handler.GetPromotions)
+	app.Post("/seller/promotions", rh.Auth.AuthorizeSeller, handler.CreatePromotion)
+	app.Get("/seller/promotions/:id", rh.Auth.AuthorizeSeller, handler.GetPromotion)
+	app.Patch("/seller/promotions/:id", rh.Auth.AuthorizeSeller, handler.EditPromotion)
+	app.Delete("/seller/promotions/:id", rh.Auth.AuthorizeSeller, handler.DeletePromotion)
+
+	// Admin endpoints, site wide promotions
+	app.Get("/admin/promotions", rh.Auth.AuthorizeAdmin, handler.GetPromotions)
+	app.Post("/admin/promotions", rh.Auth.AuthorizeAdmin, handler.CreatePromotion)
+	app.Get("/admin/promotions/:id", rh.Auth.AuthorizeAdmin, handler.GetPromotion)
+	app.Patch("/admin/promotions/:id", rh.Auth.AuthorizeAdmin, handler.EditPromotion)
+	app.Delete("/admin/promotions/:id", rh.Auth.AuthorizeAdmin, handler.DeletePromotion)
+}
+
+func (h *promotionHandler) GetPromotions(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	promotions, err := h.svc.GetPromotions(user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "promotions", promotions)
+}
+
+func (h *promotionHandler) GetPromotion(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	promotion, err := h.svc.GetPromotion(id, user)
+	if err != nil {
+		return promotionError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "promotion", promotion)
+}
+
+func (h *promotionHandler) CreatePromotion(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreatePromotionRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "create promotion request is not valid")
+	}
+	promotion, err := h.svc.CreatePromotion(req, user)
+	if err != nil {
+		return promotionError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "promotion created successfully", promotion)
+}
+
+func (h *promotionHandler) EditPromotion(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreatePromotionRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "update promotion request is not valid")
+	}
+	promotion, err := h.svc.EditPromotion(id, req, user)
+	if err != nil {
+		return promotionError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "promotion updated successfully", promotion)
+}
+
+func (h *promotionHandler) DeletePromotion(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.DeletePromotion(id, user)
+	if err != nil {
+		return promotionError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "promotion deleted successfully", nil)
+}
+
+func promotionError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrPromotionNotFound), errors.Is(err, service.ErrCategoryNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrPromotionNotOwned):
+		return rest.ErrorMessage(ctx, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrDuplicateCoupon):
+		return rest.ErrorMessage(ctx, http.StatusConflict, err)
+	case errors.Is(err, service.ErrInvalidPromotion):
+		return rest.ErrorMessage(ctx, http.StatusBadRequest, err)
+	default:
+		return rest.InternalError(ctx, err)
+	}
+}
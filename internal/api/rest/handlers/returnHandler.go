@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"ecommerce-app/internal/api/rest"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/internal/service"
+	"ecommerce-app/pkg/storage"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+)
+
+type returnHandler struct {
+	svc service.ReturnService
+}
+
+func SetupReturnRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	svc := service.ReturnService{
+		Repo:     repository.NewReturnRepository(rh.DB),
+		Payments: initializeTransactionService(rh),
+		Catalog: service.CatalogService{
+			Repo:    repository.NewCatalogRepository(rh.DB),
+			URepo:   repository.NewUserRepository(rh.DB),
+			Auth:    rh.Auth,
+			Config:  rh.Config,
+			Storage: storage.NewStorage(rh.Config),
+			Rates:   rh.Rates,
+		},
+		Auth: rh.Auth,
+	}
+	handler := returnHandler{
+		svc: svc,
+	}
+
+	// Buyer endpoints
+	app.Post("/users/order/:id/returns", rh.Auth.Authorize, handler.RequestReturn)
+	app.Get("/users/returns", rh.Auth.Authorize, handler.GetBuyerReturns)
+	app.Post("/users/returns/:id/photos", rh.Auth.Authorize, handler.AddReturnPhoto)
+
+	// Seller endpoints
+	app.Get("/seller/returns", rh.Auth.AuthorizeSeller, handler.GetSellerReturns)
+	app.Patch("/seller/returns/:id", rh.Auth.AuthorizeSeller, handler.ReviewReturn)
+	app.Post("/seller/returns/:id/receive", rh.Auth.AuthorizeSeller, handler.ReceiveReturn)
+	app.Post("/seller/returns/:id/refund", rh.Auth.AuthorizeSeller, handler.RefundReturn)
+}
+
+func (h *returnHandler) RequestReturn(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreateReturnRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "return request is not valid")
+	}
+	e, err := h.svc.RequestReturn(uint(id), req, user)
+	if err != nil {
+		return returnError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "return requested successfully", e)
+}
+
+func (h *returnHandler) AddReturnPhoto(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	data, err := readImageUpload(ctx)
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	photo, err := h.svc.AddReturnPhoto(uint(id), data, user)
+	if err != nil {
+		return returnError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "photo uploaded successfully", photo)
+}
+
+func (h *returnHandler) GetBuyerReturns(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	query := dto.ReturnListQuery{}
+	if err := ctx.QueryParser(&query); err != nil {
+		return rest.BadRequestError(ctx, "return listing parameters are not valid")
+	}
+	returns, meta, err := h.svc.GetBuyerReturns(user, query)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.PaginatedResponse(ctx, "returns", returns, meta)
+}
+
+func (h *returnHandler) GetSellerReturns(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	query := dto.ReturnListQuery{}
+	if err := ctx.QueryParser(&query); err != nil {
+		return rest.BadRequestError(ctx, "return listing parameters are not valid")
+	}
+	returns, meta, err := h.svc.GetSellerReturns(user, query)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.PaginatedResponse(ctx, "returns", returns, meta)
+}
+
+func (h *returnHandler) ReviewReturn(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.ReviewReturnRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "review return request is not valid")
+	}
+	e, err := h.svc.ReviewReturn(uint(id), req, user)
+	if err != nil {
+		return returnError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "return updated successfully", e)
+}
+
+func (h *returnHandler) ReceiveReturn(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.ReceiveReturnRequest{}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return rest.BadRequestError(ctx, "receive return request is not valid")
+		}
+	}
+	e, err := h.svc.ReceiveReturn(uint(id), req, user)
+	if err != nil {
+		return returnError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "return received successfully", e)
+}
+
+func (h *returnHandler) RefundReturn(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.RefundReturnRequest{}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return rest.BadRequestError(ctx, "refund request is not valid")
+		}
+	}
+	e, err := h.svc.RefundReturn(uint(id), req, user)
+	if err != nil {
+		return returnError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "return refunded successfully", e)
+}
+
+// returnError maps return service errors to their HTTP status
+func returnError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrReturnNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrReturnNotAllowed),
+		errors.Is(err, service.ErrReturnStatus),
+		errors.Is(err, service.ErrInvalidOrderTransition):
+		return rest.ErrorMessage(ctx, http.StatusConflict, err)
+	case errors.Is(err, service.ErrInvalidReturn),
+		errors.Is(err, service.ErrInvalidRefundAmount),
+		errors.Is(err, service.ErrTooManyReturnPhotos),
+		errors.Is(err, service.ErrImageType):
+		return rest.BadRequestError(ctx, err.Error())
+	case errors.Is(err, service.ErrImageTooLarge),
+		errors.Is(err, service.ErrImageDimensions):
+		return rest.ErrorMessage(ctx, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, service.ErrRefundFailed):
+		return rest.ErrorMessage(ctx, http.StatusBadGateway, err)
+	default:
+		return rest.InternalError(ctx, err)
+	}
+}
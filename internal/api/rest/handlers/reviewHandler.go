@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"ecommerce-app/internal/api/rest"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/internal/service"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+)
+
+type reviewHandler struct {
+	svc service.ReviewService
+}
+
+func SetupReviewRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	svc := service.ReviewService{
+		Repo:  repository.NewReviewRepository(rh.DB),
+		CRepo: repository.NewCatalogRepository(rh.DB),
+		Auth:  rh.Auth,
+	}
+	handler := reviewHandler{
+		svc: svc,
+	}
+
+	// Public endpoints
+	app.Get("/products/:id/reviews", handler.GetReviews)
+
+	// Buyer endpoints
+	app.Post("/products/:id/reviews", rh.Auth.Authorize, handler.CreateReview)
+	app.Post("/products/:id/reviews/:reviewId/helpful", rh.Auth.Authorize, handler.MarkHelpful)
+
+	// Seller endpoints
+	app.Post("/seller/products/:id/reviews/:reviewId/reply", rh.Auth.AuthorizeSeller, handler.ReplyToReview)
+}
+
+func (h *reviewHandler) GetReviews(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+
+	query := dto.ReviewListQuery{}
+	err = ctx.QueryParser(&query)
+	if err != nil {
+		return rest.BadRequestError(ctx, "review listing parameters are not valid")
+	}
+	reviews, meta, err := h.svc.GetReviews(id, query)
+	if err != nil {
+		return reviewError(ctx, err)
+	}
+	return rest.PaginatedResponse(ctx, "reviews", reviews, meta)
+}
+
+func (h *reviewHandler) CreateReview(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreateReviewRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "create review request is not valid")
+	}
+	review, err := h.svc.CreateReview(id, req, user)
+	if err != nil {
+		return reviewError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "review created successfully", review)
+}
+
+func (h *reviewHandler) MarkHelpful(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	reviewId, err := rest.IdParam(ctx, "reviewId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.MarkHelpful(id, reviewId, user)
+	if err != nil {
+		return reviewError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "vote recorded", nil)
+}
+
+func (h *reviewHandler) ReplyToReview(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	reviewId, err := rest.IdParam(ctx, "reviewId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.ReviewReplyRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "review reply request is not valid")
+	}
+	review, err := h.svc.ReplyToReview(id, reviewId, req, user)
+	if err != nil {
+		return reviewError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "reply posted successfully", review)
+}
+
+// reviewError maps review service errors to their HTTP status
+func reviewError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound), errors.Is(err, service.ErrReviewNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrNotPurchased), errors.Is(err, service.ErrProductNotOwned),
+		errors.Is(err, service.ErrOwnReviewVote):
+		return rest.ErrorMessage(ctx, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrAlreadyReviewed), errors.Is(err, service.ErrAlreadyReplied):
+		return rest.ErrorMessage(ctx, http.StatusConflict, err)
+	case errors.Is(err, service.ErrInvalidReview), errors.Is(err, service.ErrInvalidReply),
+		errors.Is(err, service.ErrInvalidReviewSort):
+		return rest.BadRequestError(ctx, err.Error())
+	default:
+		return rest.InternalError(ctx, err)
+	}
+}
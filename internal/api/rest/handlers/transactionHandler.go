@@ -2,56 +2,151 @@ package handlers
 
 import (
 	"ecommerce-app/internal/api/rest"
-	"ecommerce-app/internal/helper"
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
 	"ecommerce-app/internal/repository"
 	"ecommerce-app/internal/service"
+	"net/http"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
+	"github.com/pkg/errors"
 )
 
+// orders not paid within unpaidOrderTimeout are cancelled and their stock released, checked every unpaidOrderSweep
+const (
+	unpaidOrderTimeout = 30 * time.Minute
+	unpaidOrderSweep   = 5 * time.Minute
+)
 
 type TransactionHandler struct {
 	svc service.TransactionService
 }
 
-func initializeTransactionService(db *gorm.DB, auth helper.Auth) service.TransactionService {
+func initializeTransactionService(rh *rest.RestHandler) service.TransactionService {
 	return service.TransactionService{
-		Repo:   repository.NewTransactionRepository(db),
-		Auth: auth,
+		Repo:     repository.NewTransactionRepository(rh.DB),
+		ORepo:    repository.NewOrderRepository(rh.DB),
+		Provider: rh.Payments,
+		Auth:     rh.Auth,
+		Config:   rh.Config,
 	}
 }
 
 func SetupTransactionRoutes(as *rest.RestHandler) {
 	app := as.App
-	svc := initializeTransactionService(as.DB, as.Auth)
-	
+	svc := initializeTransactionService(as)
 
 	handler := TransactionHandler{
 		svc: svc,
 	}
 
-	secRoute := app.Group("/", as.Auth.Authorize)
-	secRoute.Get("/payment", handler.MakePayment)
+	go svc.WatchUnpaidOrders(unpaidOrderSweep, unpaidOrderTimeout)
+
+	// buyers pay for their orders, cards that need authentication are confirmed afterwards
+	app.Post("/users/order/:id/payment", as.Auth.Authorize, idempotent(as), handler.MakePayment)
+	app.Get("/users/payments/:id", as.Auth.Authorize, handler.GetPayment)
+	app.Post("/users/payments/:id/confirm", as.Auth.Authorize, idempotent(as), handler.ConfirmPayment)
 
-	sellerRoute := app.Group("/seller", as.Auth.Authorize)
+	sellerRoute := app.Group("/seller", as.Auth.AuthorizeSeller)
 	sellerRoute.Get("/orders", handler.GetOrders)
-	sellerRoute.Get("/orders/:id", handler.GetOrderDetails)
+	// order ids identify orders under /seller/orders, a single line sold is looked up by its order item id
+	sellerRoute.Get("/order-items/:id", handler.GetOrderDetails)
 }
 
 func (h *TransactionHandler) MakePayment(ctx *fiber.Ctx) error {
-	payload := struct {
-		message string `json:"message"`
-	}{
-		message: "success",
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.MakePaymentRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "payment request is not valid")
+	}
+	payment, err := h.svc.Checkout(uint(id), req, user)
+	if err != nil {
+		return paymentError(ctx, payment, err)
+	}
+	return rest.SuccessResponse(ctx, "payment "+payment.Status, payment)
+}
+
+func (h *TransactionHandler) ConfirmPayment(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	payment, err := h.svc.ConfirmPayment(uint(id), user)
+	if err != nil {
+		return paymentError(ctx, payment, err)
+	}
+	return rest.SuccessResponse(ctx, "payment "+payment.Status, payment)
+}
+
+func (h *TransactionHandler) GetPayment(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	payment, err := h.svc.GetPayment(uint(id), user)
+	if err != nil {
+		return paymentError(ctx, payment, err)
 	}
-	return ctx.Status(200).JSON(payload)
+	return rest.SuccessResponse(ctx, "payment", payment)
 }
 
 func (h *TransactionHandler) GetOrders(ctx *fiber.Ctx) error {
-	return ctx.Status(200).JSON("success")
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	query := dto.SellerOrderQuery{}
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return rest.BadRequestError(ctx, "order listing parameters are not valid")
+	}
+	orders, meta, err := h.svc.GetOrders(user, query)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.PaginatedResponse(ctx, "orders", orders, meta)
 }
 
 func (h *TransactionHandler) GetOrderDetails(ctx *fiber.Ctx) error {
-	return ctx.Status(200).JSON("success")
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	order, err := h.svc.GetOrderDetails(user, uint(id))
+	if err != nil {
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "order details", order)
+}
+
+// paymentError maps payment errors to their HTTP status, a declined payment is returned with the error
+func paymentError(ctx *fiber.Ctx, payment *domain.Payment, err error) error {
+	switch {
+	case errors.Is(err, service.ErrPaymentDeclined):
+		return ctx.Status(http.StatusPaymentRequired).JSON(fiber.Map{
+			"message": err.Error(),
+			"payment": payment,
+		})
+	case errors.Is(err, service.ErrOrderNotFound),
+		errors.Is(err, service.ErrPaymentNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrOrderNotPayable),
+		errors.Is(err, service.ErrPaymentNotPending):
+		return rest.ErrorMessage(ctx, http.StatusConflict, err)
+	case errors.Is(err, service.ErrPaymentMethod):
+		return rest.BadRequestError(ctx, err.Error())
+	default:
+		return rest.InternalError(ctx, err)
+	}
 }
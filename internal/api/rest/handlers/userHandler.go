@@ -7,7 +7,6 @@ import (
 	"ecommerce-app/internal/service"
 	"log"
 	"net/http"
-	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/pkg/errors"
@@ -23,8 +22,10 @@ func SetupUserRoutes(rh *rest.RestHandler) {
 	svc := service.UserService{
 		Repo:   repository.NewUserRepository(rh.DB),
 		CRepo:  repository.NewCatalogRepository(rh.DB),
+		PRepo:  repository.NewPromotionRepository(rh.DB),
 		Auth:   rh.Auth,
 		Config: rh.Config,
+		Rates:  rh.Rates,
 	}
 	handler := userHandler{
 		svc: svc,
@@ -46,8 +47,15 @@ func SetupUserRoutes(rh *rest.RestHandler) {
 
 	pvtRoutes.Post("/cart", handler.AddToCart)
 	pvtRoutes.Get("/cart", handler.GetCart)
-
-	pvtRoutes.Post("order", handler.CreateOrder)
+	pvtRoutes.Post("/cart/acknowledge", handler.AcknowledgeCart)
+	pvtRoutes.Post("/cart/coupon", handler.ApplyCoupon)
+	pvtRoutes.Delete("/cart/coupon", handler.RemoveCoupon)
+	pvtRoutes.Patch("/cart/:productId", handler.UpdateCartItem)
+	pvtRoutes.Delete("/cart/:productId", handler.RemoveCartItem)
+	pvtRoutes.Delete("/cart", handler.ClearCart)
+
+	// a retried checkout replays the first response instead of placing a second order
+	pvtRoutes.Post("order", idempotent(rh), handler.CreateOrder)
 	pvtRoutes.Get("order", handler.GetOrders)
 	pvtRoutes.Get("/order/:id", handler.GetOrder)
 
@@ -63,12 +71,13 @@ func (h *userHandler) Register(ctx *fiber.Ctx) error {
 			"message": "please provide valid inputs",
 		})
 	}
-	token, err := h.svc.Signup(user)
+	token, err := h.svc.Signup(user, cartToken(ctx))
 	if err != nil {
 		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "error on signup",
 		})
 	}
+	clearCartToken(ctx)
 	return ctx.Status(http.StatusOK).JSON(fiber.Map{
 		"message": "Register",
 		"token":   token,
@@ -83,12 +92,13 @@ func (h *userHandler) Login(ctx *fiber.Ctx) error {
 			"message": "please provide valid inputs",
 		})
 	}
-	token, err := h.svc.Login(loginInput.Email, loginInput.Password)
+	token, err := h.svc.Login(loginInput.Email, loginInput.Password, cartToken(ctx))
 	if err != nil {
 		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{
 			"message": "Please provide the correct login information",
 		})
 	}
+	clearCartToken(ctx)
 
 	return ctx.Status(http.StatusOK).JSON(fiber.Map{
 		"message": "Login",
@@ -183,6 +193,9 @@ func (h *userHandler) UpdateProfile(ctx *fiber.Ctx) error {
 		})
 	}
 	err := h.svc.UpdateProfile(user.ID, req)
+	if errors.Is(err, service.ErrUnsupportedCurrency) {
+		return rest.BadRequestError(ctx, err.Error())
+	}
 	if err != nil {
 		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"message": "unable to update profile",
@@ -207,15 +220,58 @@ func (h *userHandler) AddToCart(ctx *fiber.Ctx) error {
 	// call user service and perform create cart
 	cartItems, err := h.svc.CreateCart(req, user)
 	if err != nil {
-		return rest.InternalError(ctx, err)
+		return cartError(ctx, err)
 	}
 	return rest.SuccessResponse(ctx, "cart created successfully", cartItems)
 }
 
+func (h *userHandler) UpdateCartItem(ctx *fiber.Ctx) error {
+	productId, err := rest.IdParam(ctx, "productId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.UpdateCartRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "please provide a valid qty")
+	}
+	cartItems, err := h.svc.UpdateCartItem(uint(productId), req, user)
+	if err != nil {
+		return cartError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "cart updated successfully", cartItems)
+}
+
+func (h *userHandler) RemoveCartItem(ctx *fiber.Ctx) error {
+	productId, err := rest.IdParam(ctx, "productId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	variantId := ctx.QueryInt("variant_id")
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	cartItems, err := h.svc.RemoveCartItem(uint(productId), uint(variantId), user)
+	if err != nil {
+		return cartError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product removed from cart", cartItems)
+}
+
+func (h *userHandler) ClearCart(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err := h.svc.ClearCart(user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "cart cleared", nil)
+}
+
 func (h *userHandler) GetCart(ctx *fiber.Ctx) error {
 
 	user := h.svc.Auth.GetCurrentUser(ctx)
-	cart, err := h.svc.FindCart(user.ID)
+	cart, err := h.svc.GetCartSummary(user)
 	if err != nil {
 		return rest.InternalError(ctx, errors.New("cart does not exist"))
 	}
@@ -225,10 +281,61 @@ func (h *userHandler) GetCart(ctx *fiber.Ctx) error {
 	})
 }
 
+func (h *userHandler) AcknowledgeCart(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	cart, err := h.svc.AcknowledgeCart(user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "cart updated to current prices", cart)
+}
+
+func (h *userHandler) ApplyCoupon(ctx *fiber.Ctx) error {
+	req := dto.ApplyCouponRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestError(ctx, "please provide a coupon code")
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	summary, err := h.svc.ApplyCoupon(req, user)
+	if err != nil {
+		return couponError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "coupon applied", summary)
+}
+
+func (h *userHandler) RemoveCoupon(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err := h.svc.RemoveCoupon(user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "coupon removed", nil)
+}
+
 func (h *userHandler) CreateOrder(ctx *fiber.Ctx) error {
 	user := h.svc.Auth.GetCurrentUser(ctx)
-	orderRef, err := h.svc.CreateOrder(user)
-	
+	order, err := h.svc.CreateOrder(user)
+
+	var stockErr *repository.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		return ctx.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": "some items are not available in the requested quantity",
+			"lines":   stockErr.Lines,
+		})
+	}
+	if errors.Is(err, service.ErrCartChanged) {
+		cart, _ := h.svc.GetCartSummary(user)
+		return ctx.Status(http.StatusConflict).JSON(fiber.Map{
+			"message": err.Error(),
+			"cart":    cart,
+		})
+	}
+	if isCouponError(err) {
+		return couponError(ctx, err)
+	}
 	if err != nil {
 		log.Println("Error creating order:", err)
 		return rest.InternalError(ctx, errors.New("unable to create order"))
@@ -236,8 +343,9 @@ func (h *userHandler) CreateOrder(ctx *fiber.Ctx) error {
 
 
 	return ctx.Status(http.StatusOK).JSON(fiber.Map{
-		"message": "order created successfully",
-		"orderRef": orderRef,
+		"message":  "order created successfully",
+		"orderRef": order.OrderRefNumber,
+		"order_id": order.ID,
 	})
 }
 
@@ -255,7 +363,10 @@ func (h *userHandler) GetOrders(ctx *fiber.Ctx) error {
 }
 
 func (h *userHandler) GetOrder(ctx *fiber.Ctx) error {
-	orderId, _ := strconv.Atoi(ctx.Params("id"))
+	orderId, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
 	user := h.svc.Auth.GetCurrentUser(ctx)
 
 	order, err := h.svc.GetOrderById(uint(orderId), user.ID)
@@ -282,6 +393,9 @@ func (h *userHandler) BecomeSeller(ctx *fiber.Ctx) error {
 	}
 
 	token, err := h.svc.BecomeSeller(user.ID, req)
+	if errors.Is(err, service.ErrUnsupportedCurrency) {
+		return rest.BadRequestError(ctx, err.Error())
+	}
 	if err != nil {
 		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{
 			"message": "Failed to become seller",
@@ -295,3 +409,53 @@ func (h *userHandler) BecomeSeller(ctx *fiber.Ctx) error {
 		"token":   token,
 	})
 }
+
+func isCouponError(err error) bool {
+	return errors.Is(err, service.ErrCouponNotFound) ||
+		errors.Is(err, service.ErrCouponNotRunning) ||
+		errors.Is(err, service.ErrCouponMinSpend) ||
+		errors.Is(err, service.ErrCouponNotApplicable) ||
+		errors.Is(err, repository.ErrPromotionUsedUp)
+}
+
+func couponError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrCouponNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, repository.ErrPromotionUsedUp):
+		return rest.ErrorMessage(ctx, http.StatusConflict, err)
+	case isCouponError(err):
+		return rest.ErrorMessage(ctx, http.StatusUnprocessableEntity, err)
+	default:
+		return rest.InternalError(ctx, err)
+	}
+}
+
+// cartError answers every cart rejection with its own status and a stable code clients can switch on
+func cartError(ctx *fiber.Ctx, err error) error {
+	status, code := http.StatusInternalServerError, "internal_error"
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		status, code = http.StatusNotFound, "product_not_found"
+	case errors.Is(err, service.ErrVariantNotFound):
+		status, code = http.StatusNotFound, "variant_not_found"
+	case errors.Is(err, service.ErrCartItemNotFound):
+		status, code = http.StatusNotFound, "cart_item_not_found"
+	case errors.Is(err, service.ErrVariantRequired):
+		status, code = http.StatusBadRequest, "variant_required"
+	case errors.Is(err, service.ErrInvalidQty):
+		status, code = http.StatusBadRequest, "invalid_qty"
+	case errors.Is(err, service.ErrOwnProduct):
+		status, code = http.StatusForbidden, "own_product"
+	case errors.Is(err, service.ErrQtyExceedsStock):
+		status, code = http.StatusConflict, "insufficient_stock"
+	case errors.Is(err, service.ErrQtyExceedsLimit):
+		status, code = http.StatusUnprocessableEntity, "max_per_order_exceeded"
+	default:
+		return rest.InternalError(ctx, err)
+	}
+	return ctx.Status(status).JSON(fiber.Map{
+		"message": err.Error(),
+		"code":    code,
+	})
+}
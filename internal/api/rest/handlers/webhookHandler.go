@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"ecommerce-app/internal/api/rest"
+	"ecommerce-app/internal/service"
+	"ecommerce-app/pkg/payment"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+)
+
+type webhookHandler struct {
+	svc service.TransactionService
+}
+
+func SetupWebhookRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	handler := webhookHandler{
+		svc: initializeTransactionService(rh),
+	}
+
+	// called by the payment providers, requests are authenticated by their signature
+	app.Post("/webhooks/payments/:provider", handler.PaymentWebhook)
+}
+
+func (h *webhookHandler) PaymentWebhook(ctx *fiber.Ctx) error {
+	err := h.svc.HandleWebhook(ctx.Params("provider"), ctx.Get(payment.SignatureHeader), ctx.Body())
+	switch {
+	case err == nil:
+		return rest.SuccessResponse(ctx, "event received", nil)
+	case errors.Is(err, service.ErrUnknownProvider):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrInvalidSignature):
+		return rest.ErrorMessage(ctx, http.StatusUnauthorized, err)
+	case errors.Is(err, service.ErrInvalidWebhook):
+		return rest.BadRequestError(ctx, err.Error())
+	default:
+		// the provider retries the event
+		return rest.InternalError(ctx, err)
+	}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"ecommerce-app/internal/api/rest"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+)
+
+// how often wishlisted products are checked for price drops and restocks
+const wishlistAlertInterval = 10 * time.Minute
+
+type wishlistHandler struct {
+	svc service.WishlistService
+}
+
+func SetupWishlistRoutes(rh *rest.RestHandler) {
+	app := rh.App
+
+	svc := service.WishlistService{
+		Repo:  repository.NewWishlistRepository(rh.DB),
+		CRepo: repository.NewCatalogRepository(rh.DB),
+		UserSvc: service.UserService{
+			Repo:   repository.NewUserRepository(rh.DB),
+			CRepo:  repository.NewCatalogRepository(rh.DB),
+			PRepo:  repository.NewPromotionRepository(rh.DB),
+			Auth:   rh.Auth,
+			Config: rh.Config,
+			Rates:  rh.Rates,
+		},
+		Auth:   rh.Auth,
+		Config: rh.Config,
+	}
+	handler := wishlistHandler{
+		svc: svc,
+	}
+
+	go svc.WatchWishlists(wishlistAlertInterval)
+
+	// Public endpoints
+	app.Get("/wishlists/shared/:token", handler.GetSharedWishlist)
+
+	// Buyer endpoints
+	app.Get("/users/wishlists", rh.Auth.Authorize, handler.GetWishlists)
+	app.Post("/users/wishlists", rh.Auth.Authorize, handler.CreateWishlist)
+	app.Get("/users/wishlists/:id", rh.Auth.Authorize, handler.GetWishlist)
+	app.Patch("/users/wishlists/:id", rh.Auth.Authorize, handler.RenameWishlist)
+	app.Delete("/users/wishlists/:id", rh.Auth.Authorize, handler.DeleteWishlist)
+	app.Post("/users/wishlists/:id/items", rh.Auth.Authorize, handler.AddItem)
+	app.Delete("/users/wishlists/:id/items/:itemId", rh.Auth.Authorize, handler.RemoveItem)
+	app.Post("/users/wishlists/:id/items/:itemId/move-to-cart", rh.Auth.Authorize, handler.MoveItemToCart)
+	app.Post("/users/wishlists/:id/share", rh.Auth.Authorize, handler.ShareWishlist)
+	app.Delete("/users/wishlists/:id/share", rh.Auth.Authorize, handler.UnshareWishlist)
+}
+
+func (h *wishlistHandler) GetWishlists(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	wishlists, err := h.svc.GetWishlists(user)
+	if err != nil {
+		return rest.InternalError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlists", wishlists)
+}
+
+func (h *wishlistHandler) GetWishlist(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	wishlist, err := h.svc.GetWishlist(id, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist", wishlist)
+}
+
+func (h *wishlistHandler) CreateWishlist(ctx *fiber.Ctx) error {
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreateWishlistRequest{}
+	err := ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "create wishlist request is not valid")
+	}
+	wishlist, err := h.svc.CreateWishlist(req, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist created successfully", wishlist)
+}
+
+func (h *wishlistHandler) RenameWishlist(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.CreateWishlistRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "update wishlist request is not valid")
+	}
+	wishlist, err := h.svc.RenameWishlist(id, req, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist updated successfully", wishlist)
+}
+
+func (h *wishlistHandler) DeleteWishlist(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.DeleteWishlist(id, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist deleted successfully", nil)
+}
+
+func (h *wishlistHandler) AddItem(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.WishlistItemRequest{}
+	err = ctx.BodyParser(&req)
+	if err != nil {
+		return rest.BadRequestError(ctx, "wishlist item request is not valid")
+	}
+	wishlist, err := h.svc.AddItem(id, req, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product added to wishlist", wishlist)
+}
+
+func (h *wishlistHandler) RemoveItem(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	itemId, err := rest.IdParam(ctx, "itemId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.RemoveItem(id, itemId, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product removed from wishlist", nil)
+}
+
+func (h *wishlistHandler) MoveItemToCart(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	itemId, err := rest.IdParam(ctx, "itemId")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	req := dto.MoveToCartRequest{}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return rest.BadRequestError(ctx, "move to cart request is not valid")
+		}
+	}
+	cart, err := h.svc.MoveItemToCart(id, itemId, req, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "product moved to cart", cart)
+}
+
+func (h *wishlistHandler) ShareWishlist(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	share, err := h.svc.ShareWishlist(id, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist shared", share)
+}
+
+func (h *wishlistHandler) UnshareWishlist(ctx *fiber.Ctx) error {
+	id, err := rest.IdParam(ctx, "id")
+	if err != nil {
+		return rest.BadRequestError(ctx, err.Error())
+	}
+	user := h.svc.Auth.GetCurrentUser(ctx)
+
+	err = h.svc.UnshareWishlist(id, user)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist is no longer shared", nil)
+}
+
+func (h *wishlistHandler) GetSharedWishlist(ctx *fiber.Ctx) error {
+	wishlist, err := h.svc.GetSharedWishlist(ctx.Params("token"))
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist", wishlist)
+}
+
+func wishlistError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrWishlistNotFound),
+		errors.Is(err, service.ErrWishlistItemNotFound),
+		errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, service.ErrVariantNotFound):
+		return rest.ErrorMessage(ctx, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrInvalidWishlist):
+		return rest.ErrorMessage(ctx, http.StatusBadRequest, err)
+	default:
+		// moving an item to the cart fails like adding it to the cart
+		return cartError(ctx, err)
+	}
+}
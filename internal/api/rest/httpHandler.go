@@ -3,14 +3,18 @@ package rest
 import (
 	"ecommerce-app/config"
 	"ecommerce-app/internal/helper"
+	"ecommerce-app/pkg/money"
+	"ecommerce-app/pkg/payment"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 type RestHandler struct {
-	App    *fiber.App
-	DB     *gorm.DB
-	Auth   helper.Auth
-	Config config.AppConfig
+	App      *fiber.App
+	DB       *gorm.DB
+	Auth     helper.Auth
+	Config   config.AppConfig
+	Payments payment.PaymentProvider // shared, the fake provider keeps its intents in memory
+	Rates    *money.Rates            // exchange rates loaded at startup
 }
@@ -1,8 +1,10 @@
 package rest
 
 import (
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"net/http"
+	"strconv"
 )
 
 // Functions to handle errors
@@ -21,9 +23,26 @@ func BadRequestError(ctx *fiber.Ctx, msg string) error {
 	})
 }
 
+// IdParam reads a numeric id from the route, so a malformed id is reported instead of looked up as 0
+func IdParam(ctx *fiber.Ctx, name string) (int, error) {
+	id, err := strconv.Atoi(ctx.Params(name))
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("%s must be a positive number", name)
+	}
+	return id, nil
+}
+
 func SuccessResponse(ctx *fiber.Ctx, msg string, data interface{}) error {
 	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
 		"message": msg,
 		"data":    data,
 	})
 }
+
+func PaginatedResponse(ctx *fiber.Ctx, msg string, data interface{}, meta interface{}) error {
+	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+		"message": msg,
+		"data":    data,
+		"meta":    meta,
+	})
+}
@@ -6,17 +6,29 @@ import (
 	"ecommerce-app/internal/api/rest/handlers"
 	"ecommerce-app/internal/domain"
 	"ecommerce-app/internal/helper"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/money"
+	"ecommerce-app/pkg/payment"
 	"log"
 	"os"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 func StartServer(config config.AppConfig) {
-	app := fiber.New()
+	payments, err := payment.NewPaymentProvider(config)
+	if err != nil {
+		log.Fatalf("Error setting up payments: %v\n", err)
+	}
+
+	app := fiber.New(fiber.Config{
+		// leave room for image uploads and CSV imports
+		BodyLimit: 10 * 1024 * 1024,
+	})
 
 	db, err := gorm.Open(postgres.Open(config.Dsn), &gorm.Config{})
 	if err != nil {
@@ -31,15 +43,72 @@ func StartServer(config config.AppConfig) {
 		&domain.BankAccount{},
 		&domain.Category{},
 		&domain.Product{},
+		&domain.ProductOption{},
+		&domain.ProductOptionValue{},
+		&domain.ProductVariant{},
+		&domain.StockMovement{},
+		&domain.ProductImage{},
+		&domain.Review{},
+		&domain.ReviewVote{},
+		&domain.Wishlist{},
+		&domain.WishlistItem{},
 		&domain.Cart{},
+		&domain.GuestCartItem{},
+		&domain.Promotion{},
+		&domain.PromotionRedemption{},
+		&domain.CartCoupon{},
 		&domain.Order{},
-		&domain.OrderItem{},	
-		&domain.Payment{},	
+		&domain.FulfilmentGroup{},
+		&domain.OrderItem{},
+		&domain.OrderEvent{},
+		&domain.Shipment{},
+		&domain.ShipmentItem{},
+		&domain.Refund{},
+		&domain.ReturnRequest{},
+		&domain.ReturnPhoto{},
+		&domain.Payment{},
+		&domain.PaymentEvent{},
+		&domain.IdempotencyKey{},
 	)
 	if err != nil {
 		log.Fatalf("error on running the migration: %v\n", err)
 	}
 
+	err = repository.BackfillMoneyColumns(db, config.Currency)
+	if err != nil {
+		log.Fatalf("error on converting amounts to minor units: %v\n", err)
+	}
+
+	err = repository.BackfillFulfilmentGroups(db)
+	if err != nil {
+		log.Fatalf("error on splitting orders into fulfilment groups: %v\n", err)
+	}
+
+	err = repository.SetupProductSearch(db)
+	if err != nil {
+		log.Fatalf("error on setting up product search: %v\n", err)
+	}
+
+	err = repository.BackfillDefaultVariants(db)
+	if err != nil {
+		log.Fatalf("error on creating default product variants: %v\n", err)
+	}
+
+	err = repository.BackfillOpeningBalances(db)
+	if err != nil {
+		log.Fatalf("error on recording opening stock balances: %v\n", err)
+	}
+
+	rates, err := money.LoadRates(config.RatesFile, config.Currency)
+	if err != nil {
+		log.Fatalf("error on loading exchange rates: %v\n", err)
+	}
+
+	err = repository.RefreshSortPrices(db, rates, config.Currency)
+	if err != nil {
+		log.Fatalf("error on converting product prices for sorting: %v\n", err)
+	}
+
 	log.Println("Migration was successful")
 
 	// cors configuration
@@ -49,14 +118,18 @@ func StartServer(config config.AppConfig) {
 		AllowMethods: os.Getenv("CORS_ALLOWED_HEADERS"),
 	})
 	app.Use(c)
+	// a panicking handler, e.g. money of two currencies added together, fails its request instead of the server
+	app.Use(recover.New(recover.Config{EnableStackTrace: true}))
 
 	auth := helper.SetupAuth(config.AppSecret)
 
 	rh := &rest.RestHandler{
-		App:    app,
-		DB:     db,
-		Auth:   auth,
-		Config: config,
+		App:      app,
+		DB:       db,
+		Auth:     auth,
+		Config:   config,
+		Payments: payments,
+		Rates:    rates,
 	}
 	setupRoutes(rh)
 	app.Listen(config.ServerPort)
@@ -66,9 +139,30 @@ func setupRoutes(rh *rest.RestHandler) {
 	//user handler
 	handlers.SetupUserRoutes(rh)
 
+	// guest carts
+	handlers.SetupGuestCartRoutes(rh)
+
+	// order lifecycle
+	handlers.SetupOrderRoutes(rh)
+
+	// returns and refunds
+	handlers.SetupReturnRoutes(rh)
+
 	// transaction
 	handlers.SetupTransactionRoutes(rh)
 
+	// payment provider webhooks
+	handlers.SetupWebhookRoutes(rh)
+
 	// catalog
 	handlers.SetupCatalogRoutes(rh)
+
+	// reviews
+	handlers.SetupReviewRoutes(rh)
+
+	// wishlists
+	handlers.SetupWishlistRoutes(rh)
+
+	// promotions
+	handlers.SetupPromotionRoutes(rh)
 }
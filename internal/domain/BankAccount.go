@@ -5,12 +5,13 @@ import "time"
 const (
 	SELLER = "seller"
 	BUYER  = "buyer"
+	ADMIN  = "admin"
 )
 
 type BankAccount struct {
 	ID          uint      `json:"id" gorm:"PrimaryKey"`
 	UserId      uint      `json:"user_id"`
-	BankAccount uint      `json:"bank_account"gorm:"index;unique;not null"`
+	BankAccount uint      `json:"bank_account" gorm:"index;unique;not null"`
 	SwiftCode   string    `json:"swift_code"`
 	PaymentType string    `json:"payment_type"`
 	CreatedAt   time.Time `json:"created_at" gorm:"default:current_timestamp"`
@@ -1,16 +1,20 @@
 package domain
 
-import "time"
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
 
 type Cart struct {
-	ID        uint      `gorm:"PrimaryKey" json:"id"`
-	UserId    uint      `json:"user_id"`
-	ProductId uint      `json:"product_id"`
-	Name      string    `json:"name"`
-	ImageUrl  string    `json:"image_url"`
-	SellerId  uint      `json:"seller_id"`
-	Price     float64   `json:"price"`
-	Qty       uint      `json:"qty"`
-	CreatedAt time.Time `gorm:"default:current_timestamp"`
-	UpdatedAt time.Time `gorm:"default:current_timestamp"`
+	ID        uint        `gorm:"PrimaryKey" json:"id"`
+	UserId    uint        `json:"user_id"`
+	ProductId uint        `json:"product_id"`
+	VariantId uint        `json:"variant_id" gorm:"index"`
+	Name      string      `json:"name"`
+	ImageUrl  string      `json:"image_url"`
+	SellerId  uint        `json:"seller_id"`
+	Price     money.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"` // catalog price in the seller's currency when added
+	Qty       uint        `json:"qty"`
+	CreatedAt time.Time   `gorm:"default:current_timestamp"`
+	UpdatedAt time.Time   `gorm:"default:current_timestamp"`
 }
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
+
+// FulfilmentGroup is the part of an order sold by one seller. Each group is shipped on its own
+// and moves through the order statuses independently, the order status is derived from its groups.
+type FulfilmentGroup struct {
+	ID               uint        `json:"id" gorm:"PrimaryKey"`
+	OrderId          uint        `json:"order_id" gorm:"index"`
+	SellerId         uint        `json:"seller_id" gorm:"index"`
+	Status           string      `json:"status" gorm:"index"`
+	Subtotal         money.Money `json:"subtotal" gorm:"embedded;embeddedPrefix:subtotal_"`
+	Discount         money.Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Shipping         money.Money `json:"shipping" gorm:"embedded;embeddedPrefix:shipping_"`
+	ShippingDiscount money.Money `json:"shipping_discount" gorm:"embedded;embeddedPrefix:shipping_discount_"`
+	Tax              money.Money `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Amount           money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	Items            []OrderItem `json:"items" gorm:"constraint:-"` // items are inserted before their group exists
+	Shipments        []Shipment  `json:"shipments"`
+	StockReleased    bool        `json:"-" gorm:"default:false"` // set once cancelled items are back in stock
+	CreatedAt        time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt        time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// progress of an order through fulfilment, used to derive the order status from its groups
+var fulfilmentRank = map[string]int{
+	OrderStatusPendingPayment: 0,
+	OrderStatusPaid:           1,
+	OrderStatusProcessing:     2,
+	OrderStatusShipped:        3,
+	OrderStatusDelivered:      4,
+	OrderStatusCompleted:      5,
+}
+
+// AggregateOrderStatus derives the order status from the status of its fulfilment groups:
+// cancelled groups are left out, any refund makes the order partially refunded until every group is
+// refunded, otherwise the order is as far along as its slowest group.
+func AggregateOrderStatus(groups []FulfilmentGroup) string {
+	status := ""
+	refunded, cancelled := 0, 0
+	for _, g := range groups {
+		switch g.Status {
+		case OrderStatusCancelled:
+			cancelled++
+		case OrderStatusRefunded:
+			refunded++
+		case OrderStatusPartiallyRefunded:
+			return OrderStatusPartiallyRefunded
+		default:
+			if status == "" || fulfilmentRank[g.Status] < fulfilmentRank[status] {
+				status = g.Status
+			}
+		}
+	}
+	switch {
+	case refunded > 0 && refunded+cancelled == len(groups):
+		return OrderStatusRefunded
+	case refunded > 0:
+		return OrderStatusPartiallyRefunded
+	case status == "" && cancelled > 0:
+		return OrderStatusCancelled
+	}
+	return status
+}
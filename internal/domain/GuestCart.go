@@ -0,0 +1,8 @@
+package domain
+
+// GuestCartItem is a cart line of a visitor who is not logged in, identified by an opaque cart token.
+// It is kept apart from Cart because cart rows must belong to a user.
+type GuestCartItem struct {
+	Cart
+	Token string `json:"-" gorm:"index;not null"`
+}
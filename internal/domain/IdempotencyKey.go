@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// IdempotencyKey remembers the response to a request sent with an Idempotency-Key header,
+// so a retried request gets the original response instead of running again
+type IdempotencyKey struct {
+	ID          uint   `gorm:"PrimaryKey"`
+	UserId      uint   `gorm:"uniqueIndex:idx_idempotency_key"`
+	Key         string `gorm:"uniqueIndex:idx_idempotency_key;size:255"`
+	RequestHash string // method, path and body of the original request
+	StatusCode  int    `gorm:"default:0"` // 0 while the original request is still running
+	ContentType string
+	Response    []byte
+	CreatedAt   time.Time `gorm:"default:current_timestamp"`
+}
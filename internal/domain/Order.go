@@ -1,16 +1,66 @@
 package domain
 
-import "time"
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
 
+const (
+	OrderStatusPendingPayment    = "pending_payment"
+	OrderStatusPaid              = "paid"
+	OrderStatusProcessing        = "processing"
+	OrderStatusShipped           = "shipped"
+	OrderStatusDelivered         = "delivered"
+	OrderStatusCompleted         = "completed"
+	OrderStatusCancelled         = "cancelled"
+	OrderStatusRefunded          = "refunded"
+	OrderStatusPartiallyRefunded = "partially_refunded"
+)
+
+// orderTransitions lists the statuses an order can move to from each status.
+// Cancelled and refunded orders are final.
+var orderTransitions = map[string][]string{
+	OrderStatusPendingPayment:    {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:              {OrderStatusProcessing, OrderStatusCancelled, OrderStatusRefunded, OrderStatusPartiallyRefunded},
+	OrderStatusProcessing:        {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded, OrderStatusPartiallyRefunded},
+	OrderStatusShipped:           {OrderStatusDelivered, OrderStatusRefunded, OrderStatusPartiallyRefunded},
+	OrderStatusDelivered:         {OrderStatusCompleted, OrderStatusRefunded, OrderStatusPartiallyRefunded},
+	OrderStatusCompleted:         {OrderStatusRefunded, OrderStatusPartiallyRefunded},
+	OrderStatusPartiallyRefunded: {OrderStatusPartiallyRefunded, OrderStatusRefunded, OrderStatusCompleted},
+}
+
+// CanTransitionOrder reports whether an order in status from may move to status to
+func CanTransitionOrder(from string, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Order is what the buyer placed at checkout. The items are split into one fulfilment group per seller
+// and the status is derived from the groups. Amounts are in the buyer's currency at checkout.
 type Order struct {
-	ID          		uint    		`json:"id" gorm:"primaryKey"`
-	UserId      		uint    		`json:"user_id"`
-	Status      		string  		`json:"status" gorm:"index"`
-  Amount		 			float64 		`json:"amount"`
-	TransactionId 	string	 		`json:"transaction_id"`
-	PaymentId 			string 			`json:"payment_id"`
-	OrderRefNumber 	uint    		`json:"order_ref_number"`
-	Items       		[]OrderItem `json:"items"`
-	CreatedAt       time.Time   `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt       time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
-}
\ No newline at end of file
+	ID               uint              `json:"id" gorm:"primaryKey"`
+	UserId           uint              `json:"user_id"`
+	Status           string            `json:"status" gorm:"index"`
+	Amount           money.Money       `json:"amount" gorm:"embedded;embeddedPrefix:amount_"` // what the buyer pays: subtotal - discount + shipping - shipping_discount + tax
+	Subtotal         money.Money       `json:"subtotal" gorm:"embedded;embeddedPrefix:subtotal_"`
+	Discount         money.Money       `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Shipping         money.Money       `json:"shipping" gorm:"embedded;embeddedPrefix:shipping_"`
+	ShippingDiscount money.Money       `json:"shipping_discount" gorm:"embedded;embeddedPrefix:shipping_discount_"`
+	Tax              money.Money       `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	PromotionId      uint              `json:"promotion_id"`
+	CouponCode       string            `json:"coupon_code"`
+	TransactionId    string            `json:"transaction_id"`
+	PaymentId        string            `json:"payment_id"`
+	OrderRefNumber   uint              `json:"order_ref_number"`
+	Items            []OrderItem       `json:"items"`
+	Groups           []FulfilmentGroup `json:"fulfilment_groups"`
+	Events           []OrderEvent      `json:"timeline"`
+	Refunds          []Refund          `json:"refunds"`
+	Returns          []ReturnRequest   `json:"returns"`
+	CreatedAt        time.Time         `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt        time.Time         `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// OrderEvent records one status change of an order or one of its fulfilment groups, together they form the order timeline
+type OrderEvent struct {
+	ID         uint      `json:"id" gorm:"PrimaryKey"`
+	OrderId    uint      `json:"order_id" gorm:"index"`
+	GroupId    uint      `json:"fulfilment_group_id"` // 0 for changes of the order status
+	FromStatus string    `json:"from"`
+	ToStatus   string    `json:"to"`
+	ActorId    uint      `json:"actor_id"` // 0 when the system changed the status
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
@@ -1,16 +1,25 @@
 package domain
 
-import "time"
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
 
 type OrderItem struct {
-	ID 				 uint    		`json:"id" gorm:"primaryKey"`
-	OrderId 	 uint    		`json:"order_id"`
-	ProductId  uint    		`json:"product_id"`
-	Name 			 string  		`json:"name"`
-	SellerId 	 uint    		`json:"seller_id"`
-	ImageUrl 	 string  		`json:"image_url"`
-	Qty 			 uint     	`json:"qty"`
-	Price 		 float64 		`json:"price"`
-	CreatedAt  time.Time  `gorm:"default:current_timestamp"`
-	UpdatedAt  time.Time  `gorm:"default:current_timestamp"`	
+	ID                uint        `json:"id" gorm:"primaryKey"`
+	OrderId           uint        `json:"order_id"`
+	FulfilmentGroupId uint        `json:"fulfilment_group_id" gorm:"index;default:0"` // 0 until legacy orders are backfilled
+	ProductId         uint        `json:"product_id"`
+	VariantId         uint        `json:"variant_id"`
+	Sku               string      `json:"sku"`
+	Name              string      `json:"name"`
+	SellerId          uint        `json:"seller_id"`
+	ImageUrl          string      `json:"image_url"`
+	Qty               uint        `json:"qty"`
+	CancelledQty      uint        `json:"cancelled_qty" gorm:"default:0"`                            // cancelled by the buyer before shipment
+	Price             money.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"`               // unit price in the buyer's currency
+	Discount          money.Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`         // share of the order discount taken off this line
+	SellerPrice       money.Money `json:"seller_price" gorm:"embedded;embeddedPrefix:seller_price_"` // unit price in the seller's currency
+	CreatedAt         time.Time   `gorm:"default:current_timestamp"`
+	UpdatedAt         time.Time   `gorm:"default:current_timestamp"`
 }
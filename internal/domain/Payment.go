@@ -1,13 +1,25 @@
 package domain
 
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
+
+// Payment is one attempt to pay for an order through the payment provider, PaymentId is the provider's intent id
 type Payment struct {
-	ID 					 	uint    `gorm:"PrimaryKey" json:"id"`
-  UserId 			 	uint    `json:"user_id"`
-	CaptureMethod string  `json:"capture_method"`
-	Amount 		 		float64 `json:"amount"`
-	TransactionId uint  	`json:"transaction_id"`
-	CustomerId 		string  `json:"customer_id"`
-	PaymentId 		string  `json:"payment_id"`
-	Status 				string  `json:"status"`
-	Response   		string  `json:"response"`
-}
\ No newline at end of file
+	ID            uint        `gorm:"PrimaryKey" json:"id"`
+	OrderId       uint        `json:"order_id" gorm:"index;default:0"`
+	UserId        uint        `json:"user_id"`
+	Provider      string      `json:"provider"`
+	CaptureMethod string      `json:"capture_method"`
+	Amount        money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	TransactionId uint        `json:"transaction_id"`
+	ChargeId      string      `json:"charge_id"`
+	CustomerId    string      `json:"customer_id"`
+	PaymentId     string      `json:"payment_id" gorm:"index"`
+	Status        string      `json:"status"`
+	NextAction    string      `json:"next_action"` // e.g. three_d_secure while the buyer has to authenticate
+	Response      string      `json:"response"`    // last answer of the provider
+	CreatedAt     time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt     time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// PaymentEvent is a webhook received from a payment provider, stored as it arrived.
+// Each provider event is recorded once, ProcessedAt is set once it has been applied.
+type PaymentEvent struct {
+	ID          uint       `json:"id" gorm:"PrimaryKey"`
+	Provider    string     `json:"provider" gorm:"uniqueIndex:idx_payment_event"`
+	EventId     string     `json:"event_id" gorm:"uniqueIndex:idx_payment_event"`
+	Type        string     `json:"type"`
+	PaymentId   string     `json:"payment_id" gorm:"index"` // the provider's intent id
+	Payload     string     `json:"payload"`
+	Error       string     `json:"error"`
+	ProcessedAt *time.Time `json:"processed_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+}
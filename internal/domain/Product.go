@@ -1,16 +1,37 @@
 package domain
 
-import "time"
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
 
 type Product struct {
-	ID          uint      `json:"id" gorm:"PrimaryKey"`
-	Name        string    `json:"name" gorm:"index"`
-	Description string    `json:"description"`
-	CategoryId  uint      `json:"category_id"`
-	ImageUrl    string    `json:"image_url"`
-	Price       float64   `json:"price"`
-	UserId      uint      `json:"user_id" gorm:"index"`
-	Stock       uint      `json:"stock"`
-	CreatedAt   time.Time `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt   time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+	ID          uint             `json:"id" gorm:"PrimaryKey"`
+	Name        string           `json:"name" gorm:"index"`
+	Description string           `json:"description"`
+	ExternalSku string           `json:"external_sku" gorm:"index"` // seller's own reference, unique per seller
+	CategoryId  uint             `json:"category_id"`
+	ImageUrl    string           `json:"image_url"`
+	Price       money.Money      `json:"price" gorm:"embedded;embeddedPrefix:price_"` // in the seller's currency
+	SortPrice   int64            `json:"-" gorm:"index;default:0"`                    // price in the default currency, to filter and sort across currencies
+	UserId      uint             `json:"user_id" gorm:"index"`
+	Stock       uint             `json:"stock"`                          // total stock of all variants
+	MaxPerOrder uint             `json:"max_per_order" gorm:"default:0"` // 0 for no limit
+	RatingAvg   float64          `json:"rating_avg" gorm:"default:0"`
+	RatingCount uint             `json:"rating_count" gorm:"default:0"`
+	Options     []ProductOption  `json:"options,omitempty"`
+	Variants    []ProductVariant `json:"variants,omitempty"`
+	Images      []ProductImage   `json:"images,omitempty"`
+	CreatedAt   time.Time        `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   time.Time        `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// HasOptions reports whether the product is sold as explicit variants instead of a single default variant
+func (p Product) HasOptions() bool {
+	for _, v := range p.Variants {
+		if !v.IsDefault {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// ProductImage is an uploaded product image, images are shown in Position order
+type ProductImage struct {
+	ID           uint      `json:"id" gorm:"PrimaryKey"`
+	ProductId    uint      `json:"product_id" gorm:"index"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	Url          string    `json:"url"`
+	ThumbnailUrl string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Position     uint      `json:"position"`
+	CreatedAt    time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
+
+// ProductOption is an option type of a product such as "Size" or "Colour"
+type ProductOption struct {
+	ID        uint                 `json:"id" gorm:"PrimaryKey"`
+	ProductId uint                 `json:"product_id" gorm:"index"`
+	Name      string               `json:"name"`
+	Position  uint                 `json:"position"`
+	Values    []ProductOptionValue `json:"values" gorm:"foreignKey:OptionId"`
+	CreatedAt time.Time            `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time            `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+type ProductOptionValue struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	OptionId  uint      `json:"option_id" gorm:"index"`
+	Value     string    `json:"value"`
+	Position  uint      `json:"position"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// ProductVariant is a purchasable SKU of a product. Products without options
+// have a single default variant carrying the product's stock.
+type ProductVariant struct {
+	ID           uint                 `json:"id" gorm:"PrimaryKey"`
+	ProductId    uint                 `json:"product_id" gorm:"index"`
+	Sku          string               `json:"sku" gorm:"index;unique;not null"`
+	Title        string               `json:"title"`
+	Price        money.Money          `json:"price" gorm:"embedded;embeddedPrefix:price_"` // overrides the product price when set
+	Stock        uint                 `json:"stock"`
+	ImageUrl     string               `json:"image_url"`
+	IsDefault    bool                 `json:"is_default" gorm:"default:false"`
+	OptionValues []ProductOptionValue `json:"option_values" gorm:"many2many:variant_option_values"`
+	CreatedAt    time.Time            `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time            `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// PriceFor returns the variant price, falling back to the product price
+func (v ProductVariant) PriceFor(p *Product) money.Money {
+	if v.Price.IsSet() {
+		return v.Price
+	}
+	return p.Price
+}
+
+// ImageFor returns the variant image, falling back to the product image
+func (v ProductVariant) ImageFor(p *Product) string {
+	if len(v.ImageUrl) > 0 {
+		return v.ImageUrl
+	}
+	return p.ImageUrl
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
+
+const (
+	PromotionPercentage   = "percentage"
+	PromotionFixed        = "fixed"
+	PromotionFreeShipping = "free_shipping"
+	PromotionBuyXGetY     = "buy_x_get_y"
+)
+
+// Promotion is a discount redeemed with a coupon code. Sellers can only create promotions
+// scoped to their own products, admins can create site-wide ones.
+type Promotion struct {
+	ID           uint        `json:"id" gorm:"PrimaryKey"`
+	Code         string      `json:"code" gorm:"uniqueIndex;not null"` // stored upper case
+	Description  string      `json:"description"`
+	Type         string      `json:"type" gorm:"not null"`
+	Value        float64     `json:"value"`                                               // percentage: percent off
+	Amount       money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`       // fixed: amount off, converted to the buyer's currency
+	BuyQty       uint        `json:"buy_qty"`                                             // buy_x_get_y: units to pay for
+	GetQty       uint        `json:"get_qty"`                                             // buy_x_get_y: cheapest units given for free
+	MinSpend     money.Money `json:"min_spend" gorm:"embedded;embeddedPrefix:min_spend_"` // on the items the promotion applies to, unset for none
+	CategoryId   uint        `json:"category_id" gorm:"index"`                            // 0 for any category, includes sub categories
+	SellerId     uint        `json:"seller_id" gorm:"index"`                              // 0 for any seller
+	CreatedBy    uint        `json:"created_by" gorm:"index"`
+	StartsAt     *time.Time  `json:"starts_at"`
+	EndsAt       *time.Time  `json:"ends_at"`
+	UsageLimit   uint        `json:"usage_limit"`    // 0 for unlimited
+	PerUserLimit uint        `json:"per_user_limit"` // 0 for unlimited
+	UsedCount    uint        `json:"used_count" gorm:"default:0"`
+	Active       bool        `json:"active"` // no column default, so promotions can be created inactive
+	CreatedAt    time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// IsRunning reports whether the promotion is active and inside its validity window at t
+func (p *Promotion) IsRunning(t time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// PromotionRedemption records each order a promotion was used on, it backs the per user limit
+type PromotionRedemption struct {
+	ID          uint        `json:"id" gorm:"PrimaryKey"`
+	PromotionId uint        `json:"promotion_id" gorm:"index"`
+	UserId      uint        `json:"user_id" gorm:"index"`
+	OrderId     uint        `json:"order_id" gorm:"index"`
+	Code        string      `json:"code"`
+	Discount    money.Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	CreatedAt   time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+// CartCoupon is the coupon code a buyer applied to their cart
+type CartCoupon struct {
+	ID          uint      `json:"id" gorm:"PrimaryKey"`
+	UserId      uint      `json:"user_id" gorm:"uniqueIndex"`
+	PromotionId uint      `json:"promotion_id"`
+	Code        string    `json:"code"`
+	CreatedAt   time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
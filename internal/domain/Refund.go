@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund is money given back to the buyer for part of an order, issued through the payment layer
+type Refund struct {
+	ID          uint        `json:"id" gorm:"PrimaryKey"`
+	OrderId     uint        `json:"order_id" gorm:"index"`
+	GroupId     uint        `json:"fulfilment_group_id" gorm:"index"`
+	ReturnId    uint        `json:"return_id" gorm:"index;default:0"` // 0 for refunds of cancelled items
+	PaymentId   string      `json:"payment_id"`
+	Amount      money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	Reason      string      `json:"reason"`
+	Status      string      `json:"status"`
+	ProviderRef string      `json:"provider_ref"`
+	CreatedAt   time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
+}
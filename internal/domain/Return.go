@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
+
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusReceived  = "received"
+	ReturnStatusRefunded  = "refunded"
+)
+
+const (
+	ReturnResolutionRefund      = "refund"
+	ReturnResolutionReplacement = "replacement"
+)
+
+// ReturnRequest is the buyer asking to send back some quantity of a delivered order item.
+// The seller approves or rejects it, marks it received and refunds it in full or in part.
+type ReturnRequest struct {
+	ID                uint          `json:"id" gorm:"PrimaryKey"`
+	OrderId           uint          `json:"order_id" gorm:"index"`
+	FulfilmentGroupId uint          `json:"fulfilment_group_id" gorm:"index"`
+	OrderItemId       uint          `json:"order_item_id" gorm:"index"`
+	UserId            uint          `json:"user_id" gorm:"index"`
+	SellerId          uint          `json:"seller_id" gorm:"index"`
+	Qty               uint          `json:"qty"`
+	Reason            string        `json:"reason"`
+	Resolution        string        `json:"resolution"` // what the buyer asked for: refund or replacement
+	Status            string        `json:"status" gorm:"index"`
+	SellerNote        string        `json:"seller_note"`
+	Restocked         bool          `json:"restocked" gorm:"default:false"`
+	RefundedAmount    money.Money   `json:"refunded_amount" gorm:"embedded;embeddedPrefix:refunded_amount_"`
+	Photos            []ReturnPhoto `json:"photos" gorm:"foreignKey:ReturnId"`
+	CreatedAt         time.Time     `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt         time.Time     `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// ReturnPhoto is a picture of the returned item uploaded by the buyer
+type ReturnPhoto struct {
+	ID           uint      `json:"id" gorm:"PrimaryKey"`
+	ReturnId     uint      `json:"return_id" gorm:"index"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	Url          string    `json:"url"`
+	ThumbnailUrl string    `json:"thumbnail_url"`
+	CreatedAt    time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
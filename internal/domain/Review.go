@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// Review is a verified purchaser's rating of a product, one per user and product
+type Review struct {
+	ID              uint       `json:"id" gorm:"PrimaryKey"`
+	ProductId       uint       `json:"product_id" gorm:"uniqueIndex:idx_review_product_user"`
+	UserId          uint       `json:"user_id" gorm:"uniqueIndex:idx_review_product_user"`
+	Rating          uint       `json:"rating"`
+	Title           string     `json:"title"`
+	Body            string     `json:"body"`
+	HelpfulCount    uint       `json:"helpful_count" gorm:"default:0"`
+	SellerReply     string     `json:"seller_reply"`
+	SellerRepliedAt *time.Time `json:"seller_replied_at"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// ReviewVote records that a user found a review helpful, a user can vote once per review
+type ReviewVote struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	ReviewId  uint      `json:"review_id" gorm:"uniqueIndex:idx_vote_review_user"`
+	UserId    uint      `json:"user_id" gorm:"uniqueIndex:idx_vote_review_user"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
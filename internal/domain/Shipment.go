@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+const (
+	ShipmentStatusShipped   = "shipped"
+	ShipmentStatusInTransit = "in_transit"
+	ShipmentStatusDelivered = "delivered"
+)
+
+// Shipment is one parcel sent by a seller for their fulfilment group. A group can be shipped in several
+// parcels, each holding some quantity of the group's items.
+type Shipment struct {
+	ID                uint           `json:"id" gorm:"PrimaryKey"`
+	OrderId           uint           `json:"order_id" gorm:"index"`
+	FulfilmentGroupId uint           `json:"fulfilment_group_id" gorm:"index"`
+	SellerId          uint           `json:"seller_id" gorm:"index"`
+	Carrier           string         `json:"carrier"`
+	TrackingNumber    string         `json:"tracking_number"`
+	TrackingUrl       string         `json:"tracking_url"`
+	Status            string         `json:"status"`
+	Items             []ShipmentItem `json:"items"`
+	ShippedAt         time.Time      `json:"shipped_at"`
+	DeliveredAt       *time.Time     `json:"delivered_at"`
+	CreatedAt         time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt         time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+type ShipmentItem struct {
+	ID          uint `json:"id" gorm:"PrimaryKey"`
+	ShipmentId  uint `json:"shipment_id" gorm:"index"`
+	OrderItemId uint `json:"order_item_id" gorm:"index"`
+	Qty         uint `json:"qty"`
+}
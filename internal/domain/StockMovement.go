@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+const (
+	StockReasonOpening      = "opening_balance"
+	StockReasonInitial      = "initial_stock"
+	StockReasonAdjustment   = "seller_adjustment"
+	StockReasonSale         = "sale"
+	StockReasonCancellation = "cancellation_restock"
+	StockReasonReturn       = "return_restock"
+)
+
+// StockMovement is an immutable ledger entry for one change of a variant's stock
+type StockMovement struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	ProductId uint      `json:"product_id" gorm:"index"`
+	VariantId uint      `json:"variant_id" gorm:"index"`
+	Delta     int       `json:"delta"`
+	Balance   uint      `json:"balance"` // variant stock after the movement
+	Reason    string    `json:"reason" gorm:"index"`
+	ActorId   uint      `json:"actor_id"` // 0 when the system made the change
+	OrderId   uint      `json:"order_id" gorm:"index"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
@@ -11,12 +11,13 @@ type User struct {
 	Password  string    `json:"password"`
 	Code      int       `json:"code"`
 	Expiry    time.Time `json:"expiry"`
-	Address   Address   `json:"address"` // relation
-	Cart			[]Cart    `json:"cart"`    // relation
-	Orders    []Order   `json:"orders"`  // relation
-	Payments	[]Payment `json:"payments"` // relation
+	Address   Address   `json:"address"`  // relation
+	Cart      []Cart    `json:"cart"`     // relation
+	Orders    []Order   `json:"orders"`   // relation
+	Payments  []Payment `json:"payments"` // relation
 	Verified  bool      `json:"verified" gorm:"default:false"`
 	UserType  string    `json:"user_type" gorm:"default:buyer"`
+	Currency  string    `json:"currency" gorm:"size:3"` // sellers price products in it, buyers see prices in it, empty for the default
 	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"default:current_timestamp"`
 }
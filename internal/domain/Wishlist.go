@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
+
+// Wishlist is a named list of products a buyer wants to keep an eye on
+type Wishlist struct {
+	ID         uint           `json:"id" gorm:"PrimaryKey"`
+	UserId     uint           `json:"user_id" gorm:"index"`
+	Name       string         `json:"name"`
+	ShareToken *string        `json:"share_token" gorm:"uniqueIndex"` // set while the list is shared publicly
+	Items      []WishlistItem `json:"items"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt  time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// WishlistItem remembers the last price and stock the buyer was told about,
+// so price drops and restocks can be detected later.
+type WishlistItem struct {
+	ID            uint        `json:"id" gorm:"PrimaryKey"`
+	WishlistId    uint        `json:"wishlist_id" gorm:"uniqueIndex:idx_wishlist_item"`
+	ProductId     uint        `json:"product_id" gorm:"uniqueIndex:idx_wishlist_item;index"`
+	VariantId     uint        `json:"variant_id" gorm:"uniqueIndex:idx_wishlist_item"` // 0 for any variant of the product
+	Product       Product     `json:"product" gorm:"constraint:OnDelete:CASCADE"`
+	LastSeenPrice money.Money `json:"last_seen_price" gorm:"embedded;embeddedPrefix:last_seen_price_"`
+	LastSeenStock uint        `json:"last_seen_stock"`
+	CreatedAt     time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt     time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -2,5 +2,40 @@ package dto
 
 type CreateCartRequest struct {
 	ProductId uint `json:"product_id"`
+	VariantId uint `json:"variant_id"` // optional for products without options
 	Qty       uint `json:"qty"`
 }
+
+type UpdateCartRequest struct {
+	VariantId uint `json:"variant_id"` // required when the product is in the cart in more than one variant
+	Qty       uint `json:"qty"`
+}
+
+type OrderStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type OrderLineRequest struct {
+	OrderItemId uint `json:"order_item_id"`
+	Qty         uint `json:"qty"`
+}
+
+type CreateShipmentRequest struct {
+	Carrier        string             `json:"carrier"`
+	TrackingNumber string             `json:"tracking_number"`
+	TrackingUrl    string             `json:"tracking_url"`
+	Items          []OrderLineRequest `json:"items"` // empty ships everything not shipped yet
+}
+
+type UpdateShipmentRequest struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+	TrackingUrl    string `json:"tracking_url"`
+	Status         string `json:"status"` // in_transit or delivered
+}
+
+type CancelOrderRequest struct {
+	Reason string             `json:"reason"`
+	Items  []OrderLineRequest `json:"items"` // empty cancels the whole order
+}
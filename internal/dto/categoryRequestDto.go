@@ -1,8 +1,24 @@
 package dto
 
 type CreateCategoryRequest struct {
-	Name         string `json:"name"`
-	ParentId     uint   `json:"parent_id"`
+	Name string `json:"name"`
+	// ParentId 0 is a root category, on edit leaving it out keeps the current parent
+	ParentId     *uint  `json:"parent_id"`
 	ImageUrl     string `json:"image_url"`
 	DisplayOrder uint   `json:"display_order"`
 }
+
+// CategoryNode is a category with its sub categories nested below it
+type CategoryNode struct {
+	ID           uint            `json:"id"`
+	Name         string          `json:"name"`
+	ParentId     uint            `json:"parent_id"`
+	ImageUrl     string          `json:"image_url"`
+	DisplayOrder uint            `json:"display_order"`
+	Children     []*CategoryNode `json:"children"`
+}
+
+type Breadcrumb struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
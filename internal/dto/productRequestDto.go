@@ -1,14 +1,70 @@
 package dto
 
+import "ecommerce-app/pkg/money"
+
 type CreateProductRequest struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	CategoryId  uint    `json:"category_id"`
-	ImageUrl    string  `json:"image_url"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	CategoryId  uint        `json:"category_id"`
+	ImageUrl    string      `json:"image_url"`
+	Price       money.Money `json:"price"` // in the seller's currency, which is assumed when currency is left out
+	Stock       int         `json:"stock"`
+	ExternalSku string      `json:"external_sku"`
+	MaxPerOrder uint        `json:"max_per_order"` // 0 for no limit
+}
+
+// ProductImportRowError explains why one CSV row was not imported. Row 1 is the header.
+type ProductImportRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type ProductImportResult struct {
+	Created int                     `json:"created"`
+	Updated int                     `json:"updated"`
+	Errors  []ProductImportRowError `json:"errors"`
 }
 
 type UpdateStockRequest struct {
 	Stock int `json:"stock"`
 }
+
+// ProductListQuery is parsed from the query string of the product listing endpoint
+type ProductListQuery struct {
+	Page                 int    `query:"page"`
+	Limit                int    `query:"limit"`
+	Cursor               string `query:"cursor"`
+	CategoryId           uint   `query:"category_id"`
+	IncludeSubcategories bool   `query:"include_subcategories"`
+	SellerId             uint   `query:"seller_id"`
+	MinPrice             string `query:"min_price"` // decimal amounts in currency, e.g. 19.99
+	MaxPrice             string `query:"max_price"`
+	Currency             string `query:"currency"` // of the price filters, the default currency when empty
+	InStock              bool   `query:"in_stock"`
+	Sort                 string `query:"sort"`
+	Order                string `query:"order"`
+}
+
+// ProductSearchQuery accepts the listing filters alongside the search text
+type ProductSearchQuery struct {
+	ProductListQuery
+	Q string `query:"q"`
+}
+
+// PageQuery is the page/limit pair of paginated endpoints
+type PageQuery struct {
+	Page  int `query:"page"`
+	Limit int `query:"limit"`
+}
+
+type PageMeta struct {
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Fuzzy      bool   `json:"fuzzy,omitempty"`
+}
+
+type ReorderImagesRequest struct {
+	ImageIds []uint `json:"image_ids"`
+}
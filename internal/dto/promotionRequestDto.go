@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"ecommerce-app/pkg/money"
+	"time"
+)
+
+type CreatePromotionRequest struct {
+	Code         string      `json:"code"`
+	Description  string      `json:"description"`
+	Type         string      `json:"type"`   // percentage, fixed, free_shipping or buy_x_get_y
+	Value        float64     `json:"value"`  // percent off for percentage
+	Amount       money.Money `json:"amount"` // amount off for fixed
+	BuyQty       uint        `json:"buy_qty"`
+	GetQty       uint        `json:"get_qty"`
+	MinSpend     money.Money `json:"min_spend"` // in the currency of amount for fixed promotions
+	CategoryId   uint        `json:"category_id"`
+	SellerId     uint        `json:"seller_id"` // ignored for sellers, their promotions always apply to their own products
+	StartsAt     *time.Time  `json:"starts_at"`
+	EndsAt       *time.Time  `json:"ends_at"`
+	UsageLimit   uint        `json:"usage_limit"`
+	PerUserLimit uint        `json:"per_user_limit"`
+	Active       *bool       `json:"active"` // defaults to true
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code"`
+}
+
+type CartLine struct {
+	CartId    uint        `json:"cart_id"`
+	ProductId uint        `json:"product_id"`
+	VariantId uint        `json:"variant_id"`
+	SellerId  uint        `json:"seller_id"`
+	Name      string      `json:"name"`
+	ImageUrl  string      `json:"image_url"`
+	Qty       uint        `json:"qty"`
+	Price     money.Money `json:"price"` // in the buyer's currency
+	LineTotal money.Money `json:"line_total"`
+	Discount  money.Money `json:"discount"`
+	// ok, repriced, removed or insufficient_stock
+	Status        string       `json:"status"`
+	PreviousPrice *money.Money `json:"previous_price,omitempty"` // the price the item was added at, set when repriced
+	Available     uint         `json:"available"`
+	SellerPrice   money.Money  `json:"seller_price"` // the catalog price in the seller's currency
+}
+
+// CartSellerTotals are the totals of one seller's part of the cart, each seller becomes a fulfilment group
+type CartSellerTotals struct {
+	SellerId         uint        `json:"seller_id"`
+	Subtotal         money.Money `json:"subtotal"`
+	Discount         money.Money `json:"discount"`
+	Shipping         money.Money `json:"shipping"`
+	ShippingDiscount money.Money `json:"shipping_discount"`
+	Tax              money.Money `json:"tax"`
+	Total            money.Money `json:"total"`
+}
+
+// CartSummary prices the cart in the buyer's currency, converted from each seller's currency at the loaded rates
+type CartSummary struct {
+	Currency         string             `json:"currency"`
+	Items            []CartLine         `json:"items"`
+	Sellers          []CartSellerTotals `json:"sellers"`
+	Subtotal         money.Money        `json:"subtotal"`
+	Discount         money.Money        `json:"discount"`
+	Shipping         money.Money        `json:"shipping"`
+	ShippingDiscount money.Money        `json:"shipping_discount"`
+	Tax              money.Money        `json:"tax"`
+	Total            money.Money        `json:"total"`
+	CouponCode       string             `json:"coupon_code,omitempty"`
+	CouponError      string             `json:"coupon_error,omitempty"` // why the applied coupon gives no discount
+	// set when lines were repriced or removed, checkout waits until the buyer acknowledges them
+	NeedsAcknowledgement bool `json:"needs_acknowledgement"`
+}
@@ -0,0 +1,28 @@
+package dto
+
+import "ecommerce-app/pkg/money"
+
+type CreateReturnRequest struct {
+	OrderItemId uint   `json:"order_item_id"`
+	Qty         uint   `json:"qty"`
+	Reason      string `json:"reason"`
+	Resolution  string `json:"resolution"` // refund (default) or replacement
+}
+
+type ReviewReturnRequest struct {
+	Status string `json:"status"` // approved or rejected
+	Note   string `json:"note"`
+}
+
+type ReceiveReturnRequest struct {
+	Restock bool `json:"restock"`
+}
+
+type RefundReturnRequest struct {
+	Amount money.Money `json:"amount"` // null refunds what was paid for the returned items
+}
+
+type ReturnListQuery struct {
+	PageQuery
+	Status string `query:"status"`
+}
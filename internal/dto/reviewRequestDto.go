@@ -0,0 +1,16 @@
+package dto
+
+type CreateReviewRequest struct {
+	Rating uint   `json:"rating"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type ReviewReplyRequest struct {
+	Reply string `json:"reply"`
+}
+
+type ReviewListQuery struct {
+	PageQuery
+	Sort string `query:"sort"` // newest (default) or helpful
+}
@@ -1,18 +1,31 @@
 package dto
 
+import "ecommerce-app/pkg/money"
+
 type SellerOrderDetails struct {
-	OrderRefNumber 	int 		`json:"order_ref_number"`
-	OrderStatus    	string 	`json:"order_status"`
-	CreatedAt 		 	string 	`json:"created_at"`
-	OrderItemId	 	 	uint 		`json:"order_item_id"`
-	ProductId      	uint   	`json:"product_id"`
-	Name 				   	string 	`json:"name"`
-	ImageUrl 		   	string 	`json:"image_url"`
-	Price 			   	string 	`json:"price"`
-	Qty 				   	uint   	`json:"qty"`
-	CustomerName   	string 	`json:"customer_name"`
-	CustomerEmail  	string 	`json:"customer_email"`
-	CustomerPhone  	string 	`json:"customer_phone"`
-	CustomerAddress string 	`json:"customer_address"`
+	OrderId           uint        `json:"order_id"`
+	OrderRefNumber    int         `json:"order_ref_number"`
+	FulfilmentGroupId uint        `json:"fulfilment_group_id"`
+	OrderStatus       string      `json:"order_status"` // status of the seller's fulfilment group
+	CreatedAt         string      `json:"created_at"`
+	OrderItemId       uint        `json:"order_item_id"`
+	ProductId         uint        `json:"product_id"`
+	Sku               string      `json:"sku"`
+	Name              string      `json:"name"`
+	ImageUrl          string      `json:"image_url"`
+	Price             money.Money `json:"price"` // unit price in the seller's currency
+	Qty               uint        `json:"qty"`
+	CustomerName      string      `json:"customer_name"`
+	CustomerEmail     string      `json:"customer_email"`
+	CustomerPhone     string      `json:"customer_phone"`
+	CustomerAddress   string      `json:"customer_address"`
+}
+
+type SellerOrderQuery struct {
+	PageQuery
+	Status string `query:"status"`
 }
 
+type MakePaymentRequest struct {
+	PaymentMethod string `json:"payment_method"` // a test card number with the fake provider
+}
@@ -22,6 +22,7 @@ type SellerInput struct {
 	BankAccountNumber uint   `json:"bankAccountNumber"`
 	SwiftCode         string `json:"swiftCode"`
 	PaymentType       string `json:"paymentType"`
+	Currency          string `json:"currency"` // ISO code the seller prices products in, the default currency when empty
 }
 
 type AddressInput struct {
@@ -36,5 +37,6 @@ type ProfileInput struct {
 	FirstName    string       `json:"first_name"`
 	LastName     string       `json:"last_name"`
 	Email        string       `json:"email"`
+	Currency     string       `json:"currency"` // ISO code prices are shown and charged in
 	AddressInput AddressInput `json:"address"`
 }
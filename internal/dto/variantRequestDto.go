@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/pkg/money"
+)
+
+// CreateVariantRequest creates or fully replaces a variant. Options maps an option
+// name to its value (e.g. "Size": "M"); missing options and values are created.
+type CreateVariantRequest struct {
+	Sku      string            `json:"sku"`
+	Price    money.Money       `json:"price"` // null to use the product price
+	Stock    int               `json:"stock"`
+	ImageUrl string            `json:"image_url"`
+	Options  map[string]string `json:"options"`
+}
+
+type CreateOptionRequest struct {
+	Name     string   `json:"name"`
+	Position uint     `json:"position"`
+	Values   []string `json:"values"`
+}
+
+type ProductVariantsResponse struct {
+	Options  []*domain.ProductOption  `json:"options"`
+	Variants []*domain.ProductVariant `json:"variants"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+type CreateWishlistRequest struct {
+	Name string `json:"name"`
+}
+
+type WishlistItemRequest struct {
+	ProductId uint `json:"product_id"`
+	VariantId uint `json:"variant_id"` // optional, 0 follows the product as a whole
+}
+
+type MoveToCartRequest struct {
+	Qty       uint `json:"qty"`
+	VariantId uint `json:"variant_id"` // picks a variant for items saved without one
+}
+
+type WishlistShareResponse struct {
+	ShareToken string `json:"share_token"`
+	ShareUrl   string `json:"share_url"`
+}
@@ -158,3 +158,31 @@ func (a Auth) AuthorizeSeller(ctx *fiber.Ctx) error {
 		})
 	}
 }
+
+// AuthorizeAdmin only lets through users whose user_type is admin. Admins are promoted directly in the database.
+func (a Auth) AuthorizeAdmin(ctx *fiber.Ctx) error {
+
+	authHeader := ctx.GetReqHeaders()["Authorization"]
+	if len(authHeader) < 1 {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "You are not authorized",
+			"reason":  "Authorization required",
+		})
+	}
+
+	user, err := a.VerifyToken(authHeader[0])
+	if err != nil {
+		return ctx.Status(401).JSON(&fiber.Map{
+			"message": "Authorization Failed",
+			"reason":  err,
+		})
+	} else if user.ID > 0 && user.UserType == domain.ADMIN {
+		ctx.Locals("user", user)
+		return ctx.Next()
+	} else {
+		return ctx.Status(403).JSON(&fiber.Map{
+			"message": "Authorization Failed",
+			"reason":  errors.New("admin access is required"),
+		})
+	}
+}
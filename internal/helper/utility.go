@@ -2,6 +2,7 @@ package helper
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"strconv"
 )
 
@@ -21,3 +22,13 @@ func RandomNumbers(length int) (int, error) {
 	}
 	return strconv.Atoi(string(buffer))
 }
+
+// RandomToken returns a hex encoded random string built from the given number of bytes
+func RandomToken(length int) (string, error) {
+	buffer := make([]byte, length)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/pkg/money"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOrderShipped        = errors.New("order has already shipped and can no longer be cancelled")
+	ErrInvalidCancellation = errors.New("cancelled items are not valid")
+	ErrRefundNotFound      = errors.New("refund does not exist")
+	ErrRefundNotRetryable  = errors.New("only failed refunds of cancelled items can be retried")
+	ErrRefundFailed        = errors.New("refund could not be issued, please try again")
+)
+
+// Cancellation is what the buyer cancelled from one seller's fulfilment group
+type Cancellation struct {
+	GroupId  uint
+	SellerId uint
+	Items    map[uint]uint  // order item id to cancelled quantity
+	Whole    bool           // every remaining item of the group was cancelled
+	Refund   *domain.Refund // pending refund when the group was paid for
+	Repriced bool           // the group was not paid for yet, its amounts and the order's were lowered
+}
+
+// statuses in which a fulfilment group can still be cancelled by the buyer
+var cancellableStatuses = map[string]bool{
+	domain.OrderStatusPendingPayment: true,
+	domain.OrderStatusPaid:           true,
+	domain.OrderStatusProcessing:     true,
+}
+
+// CancelOrder cancels quantities of the order lines keyed by order item id, or every line still open
+// when lines is empty. Cancelled quantities go back in stock, a group with nothing left is cancelled
+// and a pending refund is recorded for every group that was paid for. Groups not paid for yet have
+// their amounts, and the order's, lowered instead, so checkout only charges for what is left.
+func (r orderRepository) CancelOrder(orderId uint, lines map[uint]uint, actorId uint, reason string) (*domain.Order, []Cancellation, error) {
+	var cancellations []Cancellation
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, orderId)
+		if err != nil {
+			return err
+		}
+		whole := len(lines) == 0
+		if whole {
+			lines = map[uint]uint{}
+			for _, group := range order.Groups {
+				if group.Status == domain.OrderStatusCancelled {
+					continue
+				}
+				for _, item := range group.Items {
+					if item.Qty > item.CancelledQty {
+						lines[item.ID] = item.Qty - item.CancelledQty
+					}
+				}
+			}
+			if len(lines) == 0 {
+				return errors.Wrapf(ErrInvalidOrderTransition, "%s to %s", order.Status, domain.OrderStatusCancelled)
+			}
+		}
+
+		var cancelled []domain.OrderItem
+		found := map[uint]bool{}
+		for gi := range order.Groups {
+			group := &order.Groups[gi]
+			c := Cancellation{GroupId: group.ID, SellerId: group.SellerId, Items: map[uint]uint{}}
+			left := uint(0)
+			for _, item := range group.Items {
+				qty, ok := lines[item.ID]
+				open := item.Qty - item.CancelledQty
+				if !ok {
+					left += open
+					continue
+				}
+				found[item.ID] = true
+				if qty == 0 || qty > open {
+					return errors.Wrapf(ErrInvalidCancellation, "order item %d has %d left to cancel", item.ID, open)
+				}
+				c.Items[item.ID] = qty
+				left += open - qty
+				item.Qty = qty
+				cancelled = append(cancelled, item)
+			}
+			if len(c.Items) == 0 {
+				continue
+			}
+			if !cancellableStatuses[group.Status] {
+				return errors.Wrap(ErrOrderShipped, group.Status)
+			}
+			var shipments int64
+			err = tx.Model(&domain.Shipment{}).Where("fulfilment_group_id = ?", group.ID).Count(&shipments).Error
+			if err != nil {
+				return err
+			}
+			if shipments > 0 {
+				return errors.Wrapf(ErrOrderShipped, "seller %d has shipped part of the order", group.SellerId)
+			}
+			c.Whole = left == 0
+			cancellations = append(cancellations, c)
+		}
+		for itemId := range lines {
+			if !found[itemId] {
+				return errors.Wrapf(ErrInvalidCancellation, "order item %d is not part of the order", itemId)
+			}
+		}
+
+		// everything cancelled is locked up front, groups cancelled as a whole lock a subset of it again
+		variantIds, productIds := orderLineIds(cancelled)
+		variants, err := lockVariants(tx, variantIds)
+		if err != nil {
+			return err
+		}
+		if err = lockProducts(tx, productIds); err != nil {
+			return err
+		}
+
+		for i := range cancellations {
+			c := &cancellations[i]
+			group := findGroup(order, c.SellerId)
+			paid := group.Status != domain.OrderStatusPendingPayment
+			amount := money.Zero(group.Amount.Currency)
+			if !paid {
+				cancelledItems := c.Items
+				if c.Whole {
+					cancelledItems = nil
+				}
+				if err = reduceUnpaidAmounts(tx, order, group, cancelledItems); err != nil {
+					return err
+				}
+				c.Repriced = true
+			}
+			if c.Whole {
+				if paid {
+					if amount, err = unrefundedAmount(tx, group); err != nil {
+						return err
+					}
+				}
+				if err = transitionGroup(tx, group, domain.OrderStatusCancelled, actorId, reason); err != nil {
+					return err
+				}
+			} else {
+				if err = cancelLines(tx, order, group, c.Items, variants, actorId, reason); err != nil {
+					return err
+				}
+				if paid {
+					amount = linesRefund(group, c.Items)
+				}
+			}
+			for itemId, qty := range c.Items {
+				err = tx.Model(&domain.OrderItem{}).
+					Where("id = ?", itemId).
+					Update("cancelled_qty", gorm.Expr("cancelled_qty + ?", qty)).Error
+				if err != nil {
+					return err
+				}
+			}
+			if amount.Amount > 0 {
+				c.Refund = &domain.Refund{
+					OrderId:   order.ID,
+					GroupId:   group.ID,
+					PaymentId: order.PaymentId,
+					Amount:    amount,
+					Reason:    reason,
+					Status:    domain.RefundStatusPending,
+				}
+				if err = tx.Create(c.Refund).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return syncOrderStatus(tx, order, actorId, reason)
+	})
+
+	for _, known := range []error{ErrOrderShipped, ErrInvalidCancellation, ErrInvalidOrderTransition} {
+		if errors.Is(err, known) {
+			return nil, nil, err
+		}
+	}
+	if err != nil {
+		log.Printf("error on cancelling order %v", err)
+		return nil, nil, errors.New("failed to cancel order")
+	}
+	order, err := r.FindOrderByID(orderId)
+	return order, cancellations, err
+}
+
+// cancelLines puts part of a group back in stock and notes it on the timeline, the group keeps its status
+func cancelLines(tx *gorm.DB, order *domain.Order, group *domain.FulfilmentGroup, items map[uint]uint,
+	variants map[uint]*domain.ProductVariant, actorId uint, reason string) error {
+	for _, item := range group.Items {
+		qty, ok := items[item.ID]
+		if !ok {
+			continue
+		}
+		if v, ok := variants[item.VariantId]; ok {
+			err := moveStock(tx, v, domain.StockMovement{
+				Delta:   int(qty),
+				Reason:  domain.StockReasonCancellation,
+				ActorId: actorId,
+				OrderId: order.ID,
+				Note:    fmt.Sprintf("order item %d", item.ID),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		note := fmt.Sprintf("cancelled %d x %s", qty, item.Name)
+		if len(reason) > 0 {
+			note += ": " + reason
+		}
+		if err := noteGroupEvent(tx, group, actorId, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linesRefund is what the buyer paid for the cancelled quantities: the line price less its share
+// of the discount, plus the matching share of the group's tax. Shipping is only refunded with the whole group.
+func linesRefund(group *domain.FulfilmentGroup, items map[uint]uint) money.Money {
+	net := money.Zero(group.Amount.Currency)
+	for _, item := range group.Items {
+		qty, ok := items[item.ID]
+		if !ok || item.Qty == 0 {
+			continue
+		}
+		net = net.Add(item.Price.Mul(int64(qty))).Sub(item.Discount.Share(int64(qty), int64(item.Qty)))
+	}
+	taxable := group.Subtotal.Sub(group.Discount)
+	if taxable.Amount > 0 {
+		net = net.Add(group.Tax.Share(net.Amount, taxable.Amount))
+	}
+	return net
+}
+
+// orderAmounts are the money fields orders and fulfilment groups have in common, Amount follows from them
+type orderAmounts struct {
+	Subtotal         money.Money
+	Discount         money.Money
+	Shipping         money.Money
+	ShippingDiscount money.Money
+	Tax              money.Money
+}
+
+func (a orderAmounts) total() money.Money {
+	return a.Subtotal.Sub(a.Discount).Add(a.Shipping).Sub(a.ShippingDiscount).Add(a.Tax)
+}
+
+// reduceUnpaidAmounts takes the cancelled quantities of an unpaid group off the amounts of the group
+// and its order, priced like linesRefund. A nil items cancels everything left in the group, shipping included.
+func reduceUnpaidAmounts(tx *gorm.DB, order *domain.Order, group *domain.FulfilmentGroup, items map[uint]uint) error {
+	cut := orderAmounts{
+		Subtotal:         group.Subtotal,
+		Discount:         group.Discount,
+		Shipping:         group.Shipping,
+		ShippingDiscount: group.ShippingDiscount,
+		Tax:              group.Tax,
+	}
+	if items != nil {
+		zero := money.Zero(group.Amount.Currency)
+		cut = orderAmounts{Subtotal: zero, Discount: zero, Shipping: zero, ShippingDiscount: zero, Tax: zero}
+		for _, item := range group.Items {
+			qty, ok := items[item.ID]
+			if !ok || item.Qty == 0 {
+				continue
+			}
+			cut.Subtotal = cut.Subtotal.Add(item.Price.Mul(int64(qty)))
+			cut.Discount = cut.Discount.Add(item.Discount.Share(int64(qty), int64(item.Qty)))
+		}
+		taxable := group.Subtotal.Sub(group.Discount)
+		if taxable.Amount > 0 {
+			cut.Tax = group.Tax.Share(cut.Subtotal.Sub(cut.Discount).Amount, taxable.Amount)
+		}
+	}
+
+	group.Subtotal = group.Subtotal.Sub(cut.Subtotal)
+	group.Discount = group.Discount.Sub(cut.Discount)
+	group.Shipping = group.Shipping.Sub(cut.Shipping)
+	group.ShippingDiscount = group.ShippingDiscount.Sub(cut.ShippingDiscount)
+	group.Tax = group.Tax.Sub(cut.Tax)
+	group.Amount = group.Amount.Sub(cut.total())
+	err := tx.Model(group).Updates(amountColumns(group.Subtotal, group.Discount, group.Shipping,
+		group.ShippingDiscount, group.Tax, group.Amount)).Error
+	if err != nil {
+		return err
+	}
+
+	order.Subtotal = order.Subtotal.Sub(cut.Subtotal)
+	order.Discount = order.Discount.Sub(cut.Discount)
+	order.Shipping = order.Shipping.Sub(cut.Shipping)
+	order.ShippingDiscount = order.ShippingDiscount.Sub(cut.ShippingDiscount)
+	order.Tax = order.Tax.Sub(cut.Tax)
+	order.Amount = order.Amount.Sub(cut.total())
+	return tx.Model(order).Updates(amountColumns(order.Subtotal, order.Discount, order.Shipping,
+		order.ShippingDiscount, order.Tax, order.Amount)).Error
+}
+
+// amountColumns lists the minor unit columns of the amounts, zero amounts included
+func amountColumns(subtotal, discount, shipping, shippingDiscount, tax, amount money.Money) map[string]interface{} {
+	return map[string]interface{}{
+		"subtotal_minor":          subtotal.Amount,
+		"discount_minor":          discount.Amount,
+		"shipping_minor":          shipping.Amount,
+		"shipping_discount_minor": shippingDiscount.Amount,
+		"tax_minor":               tax.Amount,
+		"amount_minor":            amount.Amount,
+	}
+}
+
+// unrefundedAmount is what is left to refund of the group after earlier partial refunds
+func unrefundedAmount(tx *gorm.DB, group *domain.FulfilmentGroup) (money.Money, error) {
+	var refunded int64
+	err := tx.Model(&domain.Refund{}).
+		Where("group_id = ? AND status <> ?", group.ID, domain.RefundStatusFailed).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Scan(&refunded).Error
+	if err != nil {
+		return money.Money{}, err
+	}
+	left := group.Amount.Sub(money.New(refunded, group.Amount.Currency))
+	return money.Max(money.Zero(left.Currency), left), nil
+}
+
+// RetryRefund issues a failed refund of cancelled items again, the refund stays locked while issue runs
+// so it is issued once. A refund failing again is kept failed and ErrRefundFailed is returned.
+func (r orderRepository) RetryRefund(id uint, issue func(refund *domain.Refund) error) (*domain.Refund, error) {
+	var refund domain.Refund
+	var issueErr error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefundNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// refunds of returns are retried by refunding the return again
+		if refund.Status != domain.RefundStatusFailed || refund.ReturnId != 0 {
+			return ErrRefundNotRetryable
+		}
+		issueErr = issue(&refund)
+		return updateRefund(tx, &refund)
+	})
+	if errors.Is(err, ErrRefundNotFound) || errors.Is(err, ErrRefundNotRetryable) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not retry refund")
+	}
+	if issueErr != nil {
+		return &refund, errors.Wrap(ErrRefundFailed, issueErr.Error())
+	}
+	return &refund, nil
+}
+
+func updateRefund(tx *gorm.DB, refund *domain.Refund) error {
+	return tx.Model(refund).Updates(map[string]interface{}{
+		"status":       refund.Status,
+		"provider_ref": refund.ProviderRef,
+	}).Error
+}
+
+// UpdateRefund records the outcome of a refund from the payment layer
+func (r orderRepository) UpdateRefund(refund *domain.Refund) error {
+	err := updateRefund(r.db, refund)
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not update refund")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"log"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// syncProductImageUrl keeps products.image_url pointing at the first image so older clients keep working
+func syncProductImageUrl(tx *gorm.DB, productId uint) error {
+	var first domain.ProductImage
+	err := tx.Where("product_id = ?", productId).Order("position, id").Limit(1).Find(&first).Error
+	if err != nil {
+		return err
+	}
+	if first.ID == 0 {
+		return nil
+	}
+	return tx.Model(&domain.Product{}).Where("id = ?", productId).Update("image_url", first.Url).Error
+}
+
+// CreateProductImage appends the image after the existing images of the product
+func (c catalogRepository) CreateProductImage(img *domain.ProductImage) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		// lock the product so concurrent uploads get distinct positions
+		var product domain.Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&product, img.ProductId).Error
+		if err != nil {
+			return err
+		}
+		var next uint
+		err = tx.Model(&domain.ProductImage{}).
+			Where("product_id = ?", img.ProductId).
+			Select("COALESCE(MAX(position) + 1, 0)").
+			Scan(&next).Error
+		if err != nil {
+			return err
+		}
+		img.Position = next
+		if err = tx.Create(img).Error; err != nil {
+			return err
+		}
+		return syncProductImageUrl(tx, img.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not save product image")
+	}
+	return nil
+}
+
+func (c catalogRepository) FindProductImages(productId uint) ([]*domain.ProductImage, error) {
+	var images []*domain.ProductImage
+	err := c.db.Where("product_id = ?", productId).Order("position, id").Find(&images).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch product images")
+	}
+	return images, nil
+}
+
+// ReorderProductImages sets the image positions to the order of ids, which must list every image of the product
+func (c catalogRepository) ReorderProductImages(productId uint, ids []uint) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		for position, id := range ids {
+			err := tx.Model(&domain.ProductImage{}).
+				Where("id = ? AND product_id = ?", id, productId).
+				Update("position", position).Error
+			if err != nil {
+				return err
+			}
+		}
+		return syncProductImageUrl(tx, productId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not reorder product images")
+	}
+	return nil
+}
+
+func (c catalogRepository) DeleteProductImage(img *domain.ProductImage) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&domain.ProductImage{}, img.ID).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&domain.ProductImage{}).
+			Where("product_id = ? AND position > ?", img.ProductId, img.Position).
+			Update("position", gorm.Expr("position - 1")).Error
+		if err != nil {
+			return err
+		}
+		var remaining int64
+		if err = tx.Model(&domain.ProductImage{}).Where("product_id = ?", img.ProductId).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return tx.Model(&domain.Product{}).Where("id = ?", img.ProductId).Update("image_url", "").Error
+		}
+		return syncProductImageUrl(tx, img.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("product image cannot be deleted")
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CatalogRepository interface {
@@ -15,21 +16,50 @@ type CatalogRepository interface {
 	FindCategoryByID(id int) (*domain.Category, error)
 	EditCategory(e *domain.Category) (*domain.Category, error)
 	DeleteCategory(id int) error
+	FindCategoryDescendantIds(id uint) ([]uint, error)
+	FindCategoryAncestors(id uint) ([]*domain.Category, error)
 
 	CreateProduct(e *domain.Product) error
 	FindProducts() ([]*domain.Product, error)
+	FindProductsByQuery(q ProductQuery) (*ProductPage, error)
+	SearchProducts(s ProductSearch) (*ProductSearchPage, error)
 	FindProductByID(id int) (*domain.Product, error)
 	FindSellerProducts(id int) ([]*domain.Product, error)
+	FindSellerProductBySku(sellerId uint, sku string) (*domain.Product, error)
+	SaveProducts(products []*domain.Product) error
 	EditProduct(e *domain.Product) (*domain.Product, error)
 	DeleteProduct(e *domain.Product) error
+
+	FindProductVariants(productId uint) ([]*domain.ProductVariant, error)
+	FindVariantByID(id uint) (*domain.ProductVariant, error)
+	FindVariantBySku(sku string) (*domain.ProductVariant, error)
+	CreateVariant(v *domain.ProductVariant, options []VariantOption, actorId uint) error
+	EditVariant(v *domain.ProductVariant, options []VariantOption, actorId uint) (*domain.ProductVariant, error)
+	DeleteVariant(v *domain.ProductVariant) error
+	FindProductOptions(productId uint) ([]*domain.ProductOption, error)
+	CreateProductOption(o *domain.ProductOption) error
+	DeleteProductOption(o *domain.ProductOption) error
+
+	CreateProductImage(img *domain.ProductImage) error
+	FindProductImages(productId uint) ([]*domain.ProductImage, error)
+	ReorderProductImages(productId uint, ids []uint) error
+	DeleteProductImage(img *domain.ProductImage) error
+
+	FindStockMovements(productId uint, page int, limit int) ([]*domain.StockMovement, int64, error)
+	ReconcileStock(productId uint) ([]StockDrift, error)
 }
 
+var ErrCategoryHasProducts = errors.New("category still has products, move them to another category first")
+
 type catalogRepository struct {
 	db *gorm.DB
 }
 
+// CreateProduct stores the product together with the default variant that holds its stock
 func (c catalogRepository) CreateProduct(e *domain.Product) error {
-	err := c.db.Model(&domain.Product{}).Create(e).Error
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		return createProduct(tx, e)
+	})
 	if err != nil {
 		log.Printf("err: %v", err)
 		return errors.New("could not create product")
@@ -37,6 +67,25 @@ func (c catalogRepository) CreateProduct(e *domain.Product) error {
 	return nil
 }
 
+func createProduct(tx *gorm.DB, e *domain.Product) error {
+	if err := tx.Model(&domain.Product{}).Omit(clause.Associations).Create(e).Error; err != nil {
+		return err
+	}
+	variant := domain.ProductVariant{
+		ProductId: e.ID,
+		Sku:       defaultSku(e.ID),
+		IsDefault: true,
+	}
+	if err := tx.Create(&variant).Error; err != nil {
+		return err
+	}
+	return moveStock(tx, &variant, domain.StockMovement{
+		Delta:   int(e.Stock),
+		Reason:  domain.StockReasonInitial,
+		ActorId: e.UserId,
+	})
+}
+
 func (c catalogRepository) FindProducts() ([]*domain.Product, error) {
 	var products []*domain.Product
 	err := c.db.Find(&products).Error
@@ -46,27 +95,70 @@ func (c catalogRepository) FindProducts() ([]*domain.Product, error) {
 	return products, nil
 }
 
+func (c catalogRepository) FindProductsByQuery(q ProductQuery) (*ProductPage, error) {
+	var total int64
+	err := q.applyFilters(c.db.Model(&domain.Product{})).Count(&total).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not count products")
+	}
+
+	tx, err := q.applyPage(q.applyFilters(c.db.Model(&domain.Product{})))
+	if err != nil {
+		return nil, err
+	}
+	var products []*domain.Product
+	err = tx.Find(&products).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch products")
+	}
+
+	return &ProductPage{
+		Products:   products,
+		Total:      total,
+		NextCursor: q.nextCursor(products),
+	}, nil
+}
+
 func (c catalogRepository) FindProductByID(id int) (*domain.Product, error) {
 	var product *domain.Product
-	err := c.db.First(&product, id).Error
+	err := c.db.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).
+		Preload("Options.Values", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position, id")
+		}).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		Preload("Variants.OptionValues").
+		Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position, id")
+		}).
+		First(&product, id).Error
 	if err != nil {
 		log.Printf("db_err: %v", err)
-		return nil, errors.New("product does not exist")
+		return nil, errors.Wrap(err, "product does not exist")
 	}
 	return product, nil
 }
 
 func (c catalogRepository) FindSellerProducts(id int) ([]*domain.Product, error) {
 	var products []*domain.Product
-	err := c.db.Where("user_id = ?", id).Find(&products).Error
+	err := c.db.Preload("Variants").Where("user_id = ?", id).Order("id").Find(&products).Error
 	if err != nil {
 		return nil, err
 	}
 	return products, nil
 }
 
+// EditProduct saves the product fields. A product without options passes its stock on to its default variant
+// as a seller adjustment, otherwise the stock stays the total of its variants.
 func (c catalogRepository) EditProduct(e *domain.Product) (*domain.Product, error) {
-	err := c.db.Save(&e).Error
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		return editProduct(tx, e)
+	})
 	if err != nil {
 		log.Printf("err: %v", err)
 		return nil, errors.New("could not edit product")
@@ -74,9 +166,94 @@ func (c catalogRepository) EditProduct(e *domain.Product) (*domain.Product, erro
 	return e, nil
 }
 
+func editProduct(tx *gorm.DB, e *domain.Product) error {
+	// the variant is locked before the product is written, the same order checkout takes them in
+	var variant domain.ProductVariant
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ? AND is_default", e.ID).
+		Limit(1).Find(&variant).Error
+	if err != nil {
+		return err
+	}
+	if err = tx.Omit(clause.Associations).Save(e).Error; err != nil {
+		return err
+	}
+	if variant.ID > 0 {
+		err = moveStock(tx, &variant, domain.StockMovement{
+			Delta:   int(e.Stock) - int(variant.Stock),
+			Reason:  domain.StockReasonAdjustment,
+			ActorId: e.UserId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return syncProductStock(tx, e.ID)
+}
+
+// FindSellerProductBySku returns gorm.ErrRecordNotFound when the seller has no product with the SKU
+func (c catalogRepository) FindSellerProductBySku(sellerId uint, sku string) (*domain.Product, error) {
+	var product domain.Product
+	err := c.db.Where("user_id = ? AND external_sku = ?", sellerId, sku).First(&product).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch product")
+	}
+	return &product, nil
+}
+
+// SaveProducts creates new products and edits existing ones in one transaction, all or nothing
+func (c catalogRepository) SaveProducts(products []*domain.Product) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range products {
+			var err error
+			if p.ID > 0 {
+				err = editProduct(tx, p)
+			} else {
+				err = createProduct(tx, p)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not save products")
+	}
+	return nil
+}
+
+// DeleteProduct removes the product along with its variants and options
 func (c catalogRepository) DeleteProduct(e *domain.Product) error {
-	err := c.db.Delete(&domain.Product{}, e.ID).Error
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`DELETE FROM variant_option_values WHERE product_variant_id IN (
+				SELECT id FROM product_variants WHERE product_id = ?)`, e.ID).Error
+		if err != nil {
+			return err
+		}
+		if err = tx.Where("product_id = ?", e.ID).Delete(&domain.ProductVariant{}).Error; err != nil {
+			return err
+		}
+		err = tx.Where("option_id IN (?)", tx.Model(&domain.ProductOption{}).Select("id").Where("product_id = ?", e.ID)).
+			Delete(&domain.ProductOptionValue{}).Error
+		if err != nil {
+			return err
+		}
+		if err = tx.Where("product_id = ?", e.ID).Delete(&domain.ProductOption{}).Error; err != nil {
+			return err
+		}
+		if err = tx.Where("product_id = ?", e.ID).Delete(&domain.ProductImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Product{}, e.ID).Error
+	})
 	if err != nil {
+		log.Printf("db_err: %v", err)
 		return errors.New("product cannot be deleted")
 	}
 	return nil
@@ -94,7 +271,7 @@ func (c catalogRepository) CreateCategory(e *domain.Category) error {
 
 func (c catalogRepository) FindCategories() ([]*domain.Category, error) {
 	var categories []*domain.Category
-	err := c.db.Find(&categories).Error
+	err := c.db.Order("display_order, name").Find(&categories).Error
 	if err != nil {
 		return nil, err
 	}
@@ -121,16 +298,86 @@ func (c catalogRepository) EditCategory(e *domain.Category) (*domain.Category, e
 	return e, nil
 }
 
+// DeleteCategory removes a category without orphaning anything below it:
+// its sub categories and products are moved up to the deleted category's parent.
+// A root category still holding products cannot be deleted, as products always need a category.
 func (c catalogRepository) DeleteCategory(id int) error {
-	err := c.db.Delete(&domain.Category{}, id).Error
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		var category domain.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&domain.Category{}).
+			Where("parent_id = ?", category.ID).
+			Update("parent_id", category.ParentId).Error
+		if err != nil {
+			return err
+		}
+		if category.ParentId == 0 {
+			var products int64
+			if err = tx.Model(&domain.Product{}).Where("category_id = ?", category.ID).Count(&products).Error; err != nil {
+				return err
+			}
+			if products > 0 {
+				return ErrCategoryHasProducts
+			}
+		}
+		err = tx.Model(&domain.Product{}).
+			Where("category_id = ?", category.ID).
+			Update("category_id", category.ParentId).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
 
+	if errors.Is(err, ErrCategoryHasProducts) {
+		return err
+	}
 	if err != nil {
 		log.Printf("db_err: %v", err)
-		return errors.New("Failed to delete category")
+		return errors.Wrap(err, "Failed to delete category")
 	}
 	return nil
 }
 
+// FindCategoryDescendantIds returns the id of the category followed by the ids of all its sub categories
+func (c catalogRepository) FindCategoryDescendantIds(id uint) ([]uint, error) {
+	var ids []uint
+	err := c.db.Raw(`
+		WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id = ?
+			UNION
+			SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT id FROM tree`, id).Scan(&ids).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch sub categories")
+	}
+	return ids, nil
+}
+
+// FindCategoryAncestors returns the path from the root category down to and including the given category
+func (c catalogRepository) FindCategoryAncestors(id uint) ([]*domain.Category, error) {
+	var categories []*domain.Category
+	err := c.db.Raw(`
+		WITH RECURSIVE path AS (
+			SELECT categories.*, 0 AS depth FROM categories WHERE id = ?
+			UNION
+			SELECT c.*, p.depth + 1 FROM categories c JOIN path p ON c.id = p.parent_id WHERE p.depth < 100
+		)
+		SELECT * FROM path ORDER BY depth DESC`, id).Scan(&categories).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch category path")
+	}
+	if len(categories) == 0 {
+		return nil, errors.New("Category does not exist")
+	}
+	return categories, nil
+}
+
 func NewCatalogRepository(db *gorm.DB) CatalogRepository {
 	return &catalogRepository{
 		db: db,
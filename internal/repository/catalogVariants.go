@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VariantOption is the value a variant has for a named option, e.g. Size: M
+type VariantOption struct {
+	Name  string
+	Value string
+}
+
+// defaultSku is the SKU given to the default variant of a product without options
+func defaultSku(productId uint) string {
+	return fmt.Sprintf("P%d-DEFAULT", productId)
+}
+
+// syncProductStock keeps products.stock equal to the total stock of the product's variants
+func syncProductStock(tx *gorm.DB, productId uint) error {
+	return tx.Exec(`UPDATE products SET stock = (
+			SELECT COALESCE(SUM(stock), 0) FROM product_variants WHERE product_id = ?
+		) WHERE id = ?`, productId, productId).Error
+}
+
+// BackfillDefaultVariants gives every product created before variants existed
+// a default variant holding its stock, and points old cart and order rows at it.
+// It is safe to run on every start.
+func BackfillDefaultVariants(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO product_variants (product_id, sku, stock, is_default)
+			SELECT p.id, 'P' || p.id || '-DEFAULT', p.stock, true FROM products p
+			WHERE NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)`).Error
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{"carts", "order_items"} {
+			err = tx.Exec(fmt.Sprintf(`UPDATE %s t SET variant_id = v.id FROM product_variants v
+				WHERE v.product_id = t.product_id AND v.is_default AND (t.variant_id IS NULL OR t.variant_id = 0)`, table)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c catalogRepository) FindProductVariants(productId uint) ([]*domain.ProductVariant, error) {
+	var variants []*domain.ProductVariant
+	err := c.db.Preload("OptionValues").Where("product_id = ?", productId).Order("id").Find(&variants).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch product variants")
+	}
+	return variants, nil
+}
+
+func (c catalogRepository) FindVariantByID(id uint) (*domain.ProductVariant, error) {
+	var variant domain.ProductVariant
+	err := c.db.Preload("OptionValues").First(&variant, id).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("variant does not exist")
+	}
+	return &variant, nil
+}
+
+func (c catalogRepository) FindVariantBySku(sku string) (*domain.ProductVariant, error) {
+	var variant domain.ProductVariant
+	err := c.db.Where("sku = ?", sku).First(&variant).Error
+	if err != nil {
+		return nil, errors.New("variant does not exist")
+	}
+	return &variant, nil
+}
+
+// CreateVariant stores a new variant with its option values, recording its stock as the initial ledger entry
+func (c catalogRepository) CreateVariant(v *domain.ProductVariant, options []VariantOption, actorId uint) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := setVariantOptions(tx, v, options); err != nil {
+			return err
+		}
+		stock := v.Stock
+		v.Stock = 0
+		if err := tx.Omit("OptionValues.*").Create(v).Error; err != nil {
+			return err
+		}
+		return moveStock(tx, v, domain.StockMovement{
+			Delta:   int(stock),
+			Reason:  domain.StockReasonInitial,
+			ActorId: actorId,
+		})
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not create variant")
+	}
+	return nil
+}
+
+// EditVariant saves the variant, recording any stock change as a seller adjustment.
+// Its option values are replaced when options are given and kept otherwise.
+func (c catalogRepository) EditVariant(v *domain.ProductVariant, options []VariantOption, actorId uint) (*domain.ProductVariant, error) {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		var current domain.ProductVariant
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, v.ID).Error
+		if err != nil {
+			return err
+		}
+		if options != nil {
+			if err = setVariantOptions(tx, v, options); err != nil {
+				return err
+			}
+		}
+		if err = tx.Omit(clause.Associations, "stock").Save(v).Error; err != nil {
+			return err
+		}
+		if err = tx.Model(v).Omit("OptionValues.*").Association("OptionValues").Replace(v.OptionValues); err != nil {
+			return err
+		}
+		err = moveStock(tx, &current, domain.StockMovement{
+			Delta:   int(v.Stock) - int(current.Stock),
+			Reason:  domain.StockReasonAdjustment,
+			ActorId: actorId,
+		})
+		if err != nil {
+			return err
+		}
+		return syncProductStock(tx, v.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not edit variant")
+	}
+	return v, nil
+}
+
+func (c catalogRepository) DeleteVariant(v *domain.ProductVariant) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(v).Association("OptionValues").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Delete(&domain.ProductVariant{}, v.ID).Error; err != nil {
+			return err
+		}
+		return syncProductStock(tx, v.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("variant cannot be deleted")
+	}
+	return nil
+}
+
+func (c catalogRepository) FindProductOptions(productId uint) ([]*domain.ProductOption, error) {
+	var options []*domain.ProductOption
+	err := c.db.Preload("Values", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Where("product_id = ?", productId).Order("position, id").Find(&options).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch product options")
+	}
+	return options, nil
+}
+
+func (c catalogRepository) CreateProductOption(o *domain.ProductOption) error {
+	err := c.db.Create(o).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not create product option")
+	}
+	return nil
+}
+
+// setVariantOptions resolves the option values of the variant, creating options and values the product
+// does not have yet, and titles the variant after them in option order, e.g. "M / Red"
+func setVariantOptions(tx *gorm.DB, v *domain.ProductVariant, options []VariantOption) error {
+	values := make([]domain.ProductOptionValue, 0, len(options))
+	for _, o := range options {
+		value, err := findOrCreateOptionValue(tx, v.ProductId, o.Name, o.Value)
+		if err != nil {
+			return err
+		}
+		values = append(values, *value)
+	}
+
+	var ordered []*domain.ProductOption
+	if err := tx.Where("product_id = ?", v.ProductId).Order("position, id").Find(&ordered).Error; err != nil {
+		return err
+	}
+	var parts []string
+	for _, o := range ordered {
+		for _, value := range values {
+			if value.OptionId == o.ID {
+				parts = append(parts, value.Value)
+			}
+		}
+	}
+	v.OptionValues = values
+	v.Title = strings.Join(parts, " / ")
+	return nil
+}
+
+// findOrCreateOptionValue returns the value of the named option of a product, creating the option and value when missing
+func findOrCreateOptionValue(tx *gorm.DB, productId uint, name string, value string) (*domain.ProductOptionValue, error) {
+	option := domain.ProductOption{}
+	err := tx.Where("product_id = ? AND lower(name) = lower(?)", productId, name).
+		Attrs(domain.ProductOption{ProductId: productId, Name: name}).
+		FirstOrCreate(&option).Error
+	if err != nil {
+		return nil, err
+	}
+	var optionValue domain.ProductOptionValue
+	err = tx.Where("option_id = ? AND lower(value) = lower(?)", option.ID, value).
+		Attrs(domain.ProductOptionValue{OptionId: option.ID, Value: value}).
+		FirstOrCreate(&optionValue).Error
+	if err != nil {
+		return nil, err
+	}
+	return &optionValue, nil
+}
+
+// DeleteProductOption removes an option type and its values, refusing while any variant still uses one of the values
+func (c catalogRepository) DeleteProductOption(o *domain.ProductOption) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		var used int64
+		err := tx.Table("variant_option_values").
+			Joins("JOIN product_option_values ON product_option_values.id = variant_option_values.product_option_value_id").
+			Where("product_option_values.option_id = ?", o.ID).
+			Count(&used).Error
+		if err != nil {
+			return err
+		}
+		if used > 0 {
+			return errors.New("option is still used by a variant")
+		}
+		if err = tx.Where("option_id = ?", o.ID).Delete(&domain.ProductOptionValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.ProductOption{}, o.ID).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("product option cannot be deleted")
+	}
+	return nil
+}
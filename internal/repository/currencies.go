@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"ecommerce-app/pkg/money"
+	"fmt"
+	"log"
+	"math/big"
+
+	"gorm.io/gorm"
+)
+
+// legacyAmount is a decimal column from before amounts were stored as money, and the money columns replacing it
+type legacyAmount struct {
+	table  string
+	column string
+	prefix string
+	where  string
+}
+
+var legacyAmounts = []legacyAmount{
+	{table: "products", column: "price", prefix: "price_"},
+	{table: "product_variants", column: "price", prefix: "price_"},
+	{table: "carts", column: "price", prefix: "price_"},
+	{table: "guest_cart_items", column: "price", prefix: "price_"},
+	{table: "wishlist_items", column: "last_seen_price", prefix: "last_seen_price_"},
+	{table: "promotions", column: "value", prefix: "amount_", where: "type = 'fixed'"},
+	{table: "promotions", column: "min_spend", prefix: "min_spend_"},
+	{table: "promotion_redemptions", column: "discount", prefix: "discount_"},
+	{table: "orders", column: "amount", prefix: "amount_"},
+	{table: "orders", column: "subtotal", prefix: "subtotal_"},
+	{table: "orders", column: "discount", prefix: "discount_"},
+	{table: "orders", column: "shipping", prefix: "shipping_"},
+	{table: "orders", column: "shipping_discount", prefix: "shipping_discount_"},
+	{table: "orders", column: "tax", prefix: "tax_"},
+	{table: "fulfilment_groups", column: "amount", prefix: "amount_"},
+	{table: "fulfilment_groups", column: "subtotal", prefix: "subtotal_"},
+	{table: "fulfilment_groups", column: "discount", prefix: "discount_"},
+	{table: "fulfilment_groups", column: "shipping", prefix: "shipping_"},
+	{table: "fulfilment_groups", column: "shipping_discount", prefix: "shipping_discount_"},
+	{table: "fulfilment_groups", column: "tax", prefix: "tax_"},
+	{table: "order_items", column: "price", prefix: "price_"},
+	{table: "order_items", column: "price", prefix: "seller_price_"},
+	{table: "order_items", column: "discount", prefix: "discount_"},
+	{table: "refunds", column: "amount", prefix: "amount_"},
+	{table: "return_requests", column: "refunded_amount", prefix: "refunded_amount_"},
+	{table: "payments", column: "amount", prefix: "amount_"},
+}
+
+// BackfillMoneyColumns converts amounts saved as decimals into minor units of their currency, the default
+// currency unless the payment recorded the one it was charged in.
+// Only rows whose money columns have no currency yet are touched, so it is safe to run on every start.
+// The decimal columns are left in place.
+func BackfillMoneyColumns(db *gorm.DB, currency string) error {
+	if _, err := money.Lookup(currency); err != nil {
+		return err
+	}
+
+	for _, l := range legacyAmounts {
+		if !db.Migrator().HasColumn(l.table, l.column) {
+			continue
+		}
+		currencyExpr := "CAST(? AS text)"
+		// payments kept the currency they were charged in next to the amount
+		if l.table == "payments" && db.Migrator().HasColumn(l.table, "currency") {
+			currencyExpr = "COALESCE(NULLIF(UPPER(currency), ''), ?)"
+		}
+		pending := db.Table(l.table).Where(fmt.Sprintf("%s IS NOT NULL AND COALESCE(%scurrency, '') = ''", l.column, l.prefix))
+		if len(l.where) > 0 {
+			pending = pending.Where(l.where)
+		}
+
+		var codes []string
+		err := pending.Session(&gorm.Session{}).Select("DISTINCT "+currencyExpr, currency).Scan(&codes).Error
+		if err != nil {
+			return err
+		}
+		for _, code := range codes {
+			c, err := money.Lookup(code)
+			if err != nil {
+				log.Printf("%s.%s amounts in %s cannot be converted: %v", l.table, l.column, code, err)
+				continue
+			}
+			scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Digits)), nil).Int64()
+			result := pending.Session(&gorm.Session{}).Where(currencyExpr+" = ?", currency, c.Code).
+				Updates(map[string]interface{}{
+					l.prefix + "minor":    gorm.Expr(fmt.Sprintf("ROUND(%s * ?)", l.column), scale),
+					l.prefix + "currency": c.Code,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				log.Printf("converted %d %s.%s amounts to %s minor units", result.RowsAffected, l.table, l.column, c.Code)
+			}
+		}
+	}
+	return nil
+}
+
+// RefreshSortPrices converts every product price to the default currency at the loaded rates,
+// so listings can filter and sort products priced in different currencies
+func RefreshSortPrices(db *gorm.DB, rates *money.Rates, currency string) error {
+	var currencies []string
+	err := db.Table("products").Distinct("price_currency").Where("COALESCE(price_currency, '') <> ''").
+		Pluck("price_currency", &currencies).Error
+	if err != nil {
+		return err
+	}
+	for _, code := range currencies {
+		factor, err := rates.Factor(code, currency)
+		if err != nil {
+			log.Printf("products priced in %s cannot be sorted by price: %v", code, err)
+			continue
+		}
+		err = db.Exec(`UPDATE products SET sort_price = ROUND(price_minor * CAST(? AS numeric)) WHERE price_currency = ?`,
+			factor.FloatString(12), code).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
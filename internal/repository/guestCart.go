@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// guest cart lines are handed out as plain cart lines so the service prices both carts the same way
+
+func guestCartLines(items []domain.GuestCartItem) []domain.Cart {
+	carts := make([]domain.Cart, 0, len(items))
+	for _, item := range items {
+		carts = append(carts, item.Cart)
+	}
+	return carts
+}
+
+func (r userRepository) FindGuestCartItems(token string) ([]domain.Cart, error) {
+	var items []domain.GuestCartItem
+	err := r.db.Where("token = ?", token).Order("id").Find(&items).Error
+	return guestCartLines(items), err
+}
+
+func (r userRepository) FindGuestCartItem(token string, vId uint) (domain.Cart, error) {
+	item := domain.GuestCartItem{}
+	err := r.db.Where("token = ? AND variant_id = ?", token, vId).First(&item).Error
+	return item.Cart, err
+}
+
+func (r userRepository) CreateGuestCart(token string, c domain.Cart) error {
+	c.UserId = 0
+	return r.db.Create(&domain.GuestCartItem{Cart: c, Token: token}).Error
+}
+
+func (r userRepository) UpdateGuestCart(token string, c domain.Cart) error {
+	return r.db.Model(&domain.GuestCartItem{}).
+		Where("id = ? AND token = ?", c.ID, token).
+		Updates(map[string]interface{}{"qty": c.Qty, "price_minor": c.Price.Amount, "price_currency": c.Price.Currency}).Error
+}
+
+func (r userRepository) DeleteGuestCartById(token string, id uint) error {
+	return r.db.Where("token = ?", token).Delete(&domain.GuestCartItem{}, id).Error
+}
+
+func (r userRepository) DeleteGuestCartItems(token string) error {
+	return r.db.Where("token = ?", token).Delete(&domain.GuestCartItem{}).Error
+}
+
+// MergeGuestCart stores the merged lines in the user's cart and deletes the guest cart.
+// Lines of the user's cart are given their new quantity and keep their price, the other lines are added.
+func (r userRepository) MergeGuestCart(token string, uId uint, lines []domain.Cart) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, line := range lines {
+			var err error
+			if line.ID > 0 {
+				err = tx.Model(&domain.Cart{}).Where("id = ? AND user_id = ?", line.ID, uId).Update("qty", line.Qty).Error
+			} else {
+				line.UserId = uId
+				err = tx.Create(&line).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Where("token = ?", token).Delete(&domain.GuestCartItem{}).Error
+	})
+	if err != nil {
+		log.Printf("error on merging guest cart %v", err)
+		return errors.New("failed to merge guest cart")
+	}
+	return nil
+}
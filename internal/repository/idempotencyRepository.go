@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	ReserveKey(k *domain.IdempotencyKey, ttl time.Duration) (bool, error)
+	SaveResponse(k *domain.IdempotencyKey) error
+	ReleaseKey(k *domain.IdempotencyKey) error
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+// ReserveKey stores the key for the user and reports true when the request should run.
+// When the key was used before, k is replaced with the stored key and false is returned.
+// Keys older than ttl are forgotten.
+func (r idempotencyRepository) ReserveKey(k *domain.IdempotencyKey, ttl time.Duration) (bool, error) {
+	var reserved bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND key = ? AND created_at < ?", k.UserId, k.Key, time.Now().Add(-ttl)).
+			Delete(&domain.IdempotencyKey{}).Error
+		if err != nil {
+			return err
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(k)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			reserved = true
+			return nil
+		}
+		return tx.Where("user_id = ? AND key = ?", k.UserId, k.Key).First(k).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return false, errors.New("could not check idempotency key")
+	}
+	return reserved, nil
+}
+
+// SaveResponse stores the response of the request that reserved the key
+func (r idempotencyRepository) SaveResponse(k *domain.IdempotencyKey) error {
+	err := r.db.Model(k).Updates(map[string]interface{}{
+		"status_code":  k.StatusCode,
+		"content_type": k.ContentType,
+		"response":     k.Response,
+	}).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not save idempotent response")
+	}
+	return nil
+}
+
+// ReleaseKey forgets the key so the request can be retried, e.g. after a server error
+func (r idempotencyRepository) ReleaseKey(k *domain.IdempotencyKey) error {
+	err := r.db.Delete(k).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not release idempotency key")
+	}
+	return nil
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
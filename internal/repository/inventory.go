@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StockShortage explains why one order line could not be fulfilled
+type StockShortage struct {
+	ProductId uint   `json:"product_id"`
+	VariantId uint   `json:"variant_id"`
+	Name      string `json:"name"`
+	Requested uint   `json:"requested"`
+	Available uint   `json:"available"`
+}
+
+// InsufficientStockError is returned by PlaceOrder when at least one line cannot be fulfilled
+type InsufficientStockError struct {
+	Lines []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	parts := make([]string, 0, len(e.Lines))
+	for _, l := range e.Lines {
+		parts = append(parts, fmt.Sprintf("%s: requested %d, available %d", l.Name, l.Requested, l.Available))
+	}
+	return "insufficient stock for " + strings.Join(parts, "; ")
+}
+
+// lockVariants locks the variant rows for the rest of the transaction, always in id order so concurrent checkouts cannot deadlock
+func lockVariants(tx *gorm.DB, ids []uint) (map[uint]*domain.ProductVariant, error) {
+	var variants []*domain.ProductVariant
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&variants).Error
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[uint]*domain.ProductVariant, len(variants))
+	for _, v := range variants {
+		byId[v.ID] = v
+	}
+	return byId, nil
+}
+
+// lockProducts locks the product rows whose stock totals are about to change
+func lockProducts(tx *gorm.DB, ids []uint) error {
+	var locked []uint
+	return tx.Model(&domain.Product{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Pluck("id", &locked).Error
+}
+
+// moveStock changes the stock of a locked variant by m.Delta, records the movement
+// in the stock ledger and keeps the product total in step. Every stock change goes through here.
+func moveStock(tx *gorm.DB, variant *domain.ProductVariant, m domain.StockMovement) error {
+	if m.Delta == 0 {
+		return nil
+	}
+	balance := int(variant.Stock) + m.Delta
+	if balance < 0 {
+		return errors.New("stock cannot go below zero")
+	}
+
+	err := tx.Model(&domain.ProductVariant{}).
+		Where("id = ?", variant.ID).
+		Update("stock", balance).Error
+	if err != nil {
+		return err
+	}
+	variant.Stock = uint(balance)
+
+	m.ID = 0
+	m.ProductId = variant.ProductId
+	m.VariantId = variant.ID
+	m.Balance = variant.Stock
+	if err = tx.Create(&m).Error; err != nil {
+		return err
+	}
+	return syncProductStock(tx, variant.ProductId)
+}
+
+func orderLineIds(items []domain.OrderItem) (variantIds []uint, productIds []uint) {
+	seenV, seenP := map[uint]bool{}, map[uint]bool{}
+	for _, item := range items {
+		if !seenV[item.VariantId] {
+			seenV[item.VariantId] = true
+			variantIds = append(variantIds, item.VariantId)
+		}
+		if !seenP[item.ProductId] {
+			seenP[item.ProductId] = true
+			productIds = append(productIds, item.ProductId)
+		}
+	}
+	sort.Slice(variantIds, func(i, j int) bool { return variantIds[i] < variantIds[j] })
+	sort.Slice(productIds, func(i, j int) bool { return productIds[i] < productIds[j] })
+	return variantIds, productIds
+}
+
+// PlaceOrder creates the order in a single transaction: it locks the ordered variants,
+// rejects the whole order with an InsufficientStockError when any line is short,
+// splits it into its fulfilment groups, decrements stock, redeems the applied coupon and empties the buyer's cart.
+func (r userRepository) PlaceOrder(o *domain.Order) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		variantIds, productIds := orderLineIds(o.Items)
+		variants, err := lockVariants(tx, variantIds)
+		if err != nil {
+			return err
+		}
+		if err = lockProducts(tx, productIds); err != nil {
+			return err
+		}
+
+		// the same variant may appear on several lines, so compare against the total requested
+		requested := map[uint]uint{}
+		for _, item := range o.Items {
+			requested[item.VariantId] += item.Qty
+		}
+		shortage := &InsufficientStockError{}
+		for _, item := range o.Items {
+			v, ok := variants[item.VariantId]
+			if ok && v.ProductId == item.ProductId && v.Stock >= requested[item.VariantId] {
+				continue
+			}
+			line := StockShortage{
+				ProductId: item.ProductId,
+				VariantId: item.VariantId,
+				Name:      item.Name,
+				Requested: item.Qty,
+			}
+			if ok {
+				line.Available = v.Stock
+			}
+			shortage.Lines = append(shortage.Lines, line)
+		}
+		if len(shortage.Lines) > 0 {
+			return shortage
+		}
+
+		// the order is created first so the ledger entries can reference it
+		if err = createOrder(tx, o); err != nil {
+			return err
+		}
+		for _, item := range o.Items {
+			err = moveStock(tx, variants[item.VariantId], domain.StockMovement{
+				Delta:   -int(item.Qty),
+				Reason:  domain.StockReasonSale,
+				ActorId: o.UserId,
+				OrderId: o.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if o.PromotionId > 0 {
+			if err = redeemPromotion(tx, o); err != nil {
+				return err
+			}
+			if err = tx.Where("user_id = ?", o.UserId).Delete(&domain.CartCoupon{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("user_id = ?", o.UserId).Delete(&domain.Cart{}).Error
+	})
+
+	var stockErr *InsufficientStockError
+	if errors.As(err, &stockErr) {
+		return stockErr
+	}
+	if errors.Is(err, ErrPromotionUsedUp) {
+		return ErrPromotionUsedUp
+	}
+	if err != nil {
+		log.Printf("error on placing order %v", err)
+		return errors.New("failed to create order")
+	}
+	return nil
+}
+
+// releaseGroupStock puts the items of the locked fulfilment group back in stock, at most once per group.
+// Quantities the buyer cancelled earlier were restocked at the time.
+// actorId is the user who cancelled, or 0 when the system released the order.
+func releaseGroupStock(tx *gorm.DB, group *domain.FulfilmentGroup, actorId uint) error {
+	if group.StockReleased {
+		return nil
+	}
+	variantIds, productIds := orderLineIds(group.Items)
+	variants, err := lockVariants(tx, variantIds)
+	if err != nil {
+		return err
+	}
+	if err = lockProducts(tx, productIds); err != nil {
+		return err
+	}
+	for _, item := range group.Items {
+		// variants deleted since the order was placed have nothing to restock
+		if v, ok := variants[item.VariantId]; ok {
+			err = moveStock(tx, v, domain.StockMovement{
+				Delta:   int(item.Qty - item.CancelledQty),
+				Reason:  domain.StockReasonCancellation,
+				ActorId: actorId,
+				OrderId: group.OrderId,
+				Note:    group.Status,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	group.StockReleased = true
+	return tx.Model(group).Update("stock_released", true).Error
+}
@@ -0,0 +1,343 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/pkg/money"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidOrderTransition = errors.New("order cannot move to the requested status")
+
+type OrderRepository interface {
+	FindOrderByID(id uint) (*domain.Order, error)
+	TransitionOrder(orderId uint, to string, actorId uint, reason string) (*domain.Order, error)
+	TransitionGroup(groupId uint, to string, actorId uint, reason string) (*domain.Order, error)
+	ReleaseUnpaidOrder(orderId uint, reason string) (bool, error)
+	FindUnpaidOrderIds(placedBefore time.Time) ([]uint, error)
+	CreateShipment(orderId uint, s *domain.Shipment) (*domain.Order, error)
+	UpdateShipment(update domain.Shipment) (*domain.Order, error)
+	CancelOrder(orderId uint, lines map[uint]uint, actorId uint, reason string) (*domain.Order, []Cancellation, error)
+	UpdateRefund(refund *domain.Refund) error
+	RetryRefund(id uint, issue func(refund *domain.Refund) error) (*domain.Refund, error)
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+// preloadOrder loads the order lines, its fulfilment groups with their shipments, its timeline, refunds and returns oldest first
+func preloadOrder(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("Items").
+		Preload("Groups", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Groups.Items").
+		Preload("Groups.Shipments", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Groups.Shipments.Items").
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Refunds", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Returns", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Returns.Photos")
+}
+
+func (r orderRepository) FindOrderByID(id uint) (*domain.Order, error) {
+	var order domain.Order
+	err := preloadOrder(r.db).First(&order, id).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("failed to find order")
+	}
+	return &order, nil
+}
+
+// TransitionOrder moves every fulfilment group of the order that can make the transition,
+// e.g. paying or cancelling the whole order, and derives the new order status.
+func (r orderRepository) TransitionOrder(orderId uint, to string, actorId uint, reason string) (*domain.Order, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, orderId)
+		if err != nil {
+			return err
+		}
+		return transitionOrder(tx, order, to, actorId, reason)
+	})
+	return r.afterTransition(orderId, err)
+}
+
+// transitionOrder moves every group of the locked order that can move to the status
+func transitionOrder(tx *gorm.DB, order *domain.Order, to string, actorId uint, reason string) error {
+	moved := 0
+	for i := range order.Groups {
+		if !domain.CanTransitionOrder(order.Groups[i].Status, to) {
+			continue
+		}
+		if err := transitionGroup(tx, &order.Groups[i], to, actorId, reason); err != nil {
+			return err
+		}
+		moved++
+	}
+	if moved == 0 {
+		return errors.Wrapf(ErrInvalidOrderTransition, "%s to %s", order.Status, to)
+	}
+	return syncOrderStatus(tx, order, actorId, reason)
+}
+
+// TransitionGroup moves one fulfilment group, e.g. when its seller ships it, and derives the new order status
+func (r orderRepository) TransitionGroup(groupId uint, to string, actorId uint, reason string) (*domain.Order, error) {
+	var orderId uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var group domain.FulfilmentGroup
+		if err := tx.Select("order_id").First(&group, groupId).Error; err != nil {
+			return err
+		}
+		orderId = group.OrderId
+		order, err := lockOrder(tx, orderId)
+		if err != nil {
+			return err
+		}
+		for i := range order.Groups {
+			if order.Groups[i].ID == groupId {
+				if err = transitionGroup(tx, &order.Groups[i], to, actorId, reason); err != nil {
+					return err
+				}
+			}
+		}
+		return syncOrderStatus(tx, order, actorId, reason)
+	})
+	return r.afterTransition(orderId, err)
+}
+
+// ReleaseUnpaidOrder cancels the order on behalf of the system and puts its items back in stock, as long as
+// it is still awaiting payment. It reports false when the order was paid or cancelled meanwhile.
+func (r orderRepository) ReleaseUnpaidOrder(orderId uint, reason string) (bool, error) {
+	released := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, orderId)
+		if err != nil {
+			return err
+		}
+		if order.Status != domain.OrderStatusPendingPayment {
+			return nil
+		}
+		for i := range order.Groups {
+			if order.Groups[i].Status != domain.OrderStatusPendingPayment {
+				continue
+			}
+			if err = reduceUnpaidAmounts(tx, order, &order.Groups[i], nil); err != nil {
+				return err
+			}
+			if err = transitionGroup(tx, &order.Groups[i], domain.OrderStatusCancelled, 0, reason); err != nil {
+				return err
+			}
+		}
+		released = true
+		return syncOrderStatus(tx, order, 0, reason)
+	})
+	if err != nil {
+		log.Printf("error on releasing unpaid order %v", err)
+		return false, errors.New("failed to release order")
+	}
+	return released, nil
+}
+
+// FindUnpaidOrderIds lists the orders still awaiting payment that were placed before the given time
+func (r orderRepository) FindUnpaidOrderIds(placedBefore time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&domain.Order{}).
+		Where("status = ? AND created_at < ?", domain.OrderStatusPendingPayment, placedBefore).
+		Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch unpaid orders")
+	}
+	return ids, nil
+}
+
+func (r orderRepository) afterTransition(orderId uint, err error) (*domain.Order, error) {
+	if errors.Is(err, ErrInvalidOrderTransition) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("error on changing order status %v", err)
+		return nil, errors.New("failed to update order status")
+	}
+	return r.FindOrderByID(orderId)
+}
+
+// lockOrder locks the order row, status changes of its groups are serialised on it
+func lockOrder(tx *gorm.DB, orderId uint) (*domain.Order, error) {
+	var order domain.Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderId).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Preload("Items").Where("order_id = ?", orderId).Order("id").Find(&order.Groups).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// createOrder inserts the order with its items, then one fulfilment group per seller holding that seller's items
+func createOrder(tx *gorm.DB, o *domain.Order) error {
+	groups := o.Groups
+	o.Groups = nil
+	o.Status = ""
+	if err := tx.Create(o).Error; err != nil {
+		return err
+	}
+	for i := range groups {
+		g := &groups[i]
+		g.OrderId = o.ID
+		g.Status = domain.OrderStatusPendingPayment
+		if err := tx.Omit("Items").Create(g).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&domain.OrderItem{}).
+			Where("order_id = ? AND seller_id = ?", o.ID, g.SellerId).
+			Update("fulfilment_group_id", g.ID).Error
+		if err != nil {
+			return err
+		}
+		for j := range o.Items {
+			if o.Items[j].SellerId == g.SellerId {
+				o.Items[j].FulfilmentGroupId = g.ID
+				g.Items = append(g.Items, o.Items[j])
+			}
+		}
+	}
+	o.Groups = groups
+	return syncOrderStatus(tx, o, o.UserId, "order placed")
+}
+
+// transitionGroup sets the status of a group of the locked order and appends it to the timeline.
+// A cancelled group puts its items back in stock.
+func transitionGroup(tx *gorm.DB, group *domain.FulfilmentGroup, to string, actorId uint, reason string) error {
+	if !domain.CanTransitionOrder(group.Status, to) {
+		return errors.Wrapf(ErrInvalidOrderTransition, "%s to %s", group.Status, to)
+	}
+	event := domain.OrderEvent{
+		OrderId:    group.OrderId,
+		GroupId:    group.ID,
+		FromStatus: group.Status,
+		ToStatus:   to,
+		ActorId:    actorId,
+		Reason:     reason,
+	}
+	if err := tx.Model(group).Update("status", to).Error; err != nil {
+		return err
+	}
+	group.Status = to
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+	if to == domain.OrderStatusCancelled {
+		return releaseGroupStock(tx, group, actorId)
+	}
+	return nil
+}
+
+// noteGroupEvent adds an entry to the timeline of the group that does not change its status
+func noteGroupEvent(tx *gorm.DB, group *domain.FulfilmentGroup, actorId uint, reason string) error {
+	return tx.Create(&domain.OrderEvent{
+		OrderId:    group.OrderId,
+		GroupId:    group.ID,
+		FromStatus: group.Status,
+		ToStatus:   group.Status,
+		ActorId:    actorId,
+		Reason:     reason,
+	}).Error
+}
+
+// syncOrderStatus derives the order status from its groups and records it when it changed.
+// The derived status is not checked against the state machine, its groups already were.
+func syncOrderStatus(tx *gorm.DB, order *domain.Order, actorId uint, reason string) error {
+	status := domain.AggregateOrderStatus(order.Groups)
+	if status == order.Status {
+		return nil
+	}
+	event := domain.OrderEvent{
+		OrderId:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   status,
+		ActorId:    actorId,
+		Reason:     reason,
+	}
+	if err := tx.Model(order).Update("status", status).Error; err != nil {
+		return err
+	}
+	order.Status = status
+	return tx.Create(&event).Error
+}
+
+// BackfillFulfilmentGroups splits orders placed before fulfilment groups existed into one group per seller,
+// each group takes the order status. Those orders were only placed once paid and never had a status,
+// so orders and groups without one become paid. It only touches orders without groups and is safe to run on every start.
+func BackfillFulfilmentGroups(db *gorm.DB) error {
+	for _, model := range []interface{}{&domain.Order{}, &domain.FulfilmentGroup{}} {
+		err := db.Model(model).Where("COALESCE(status, '') = ''").Update("status", domain.OrderStatusPaid).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	var orders []domain.Order
+	err := db.Preload("Items").
+		Where("NOT EXISTS (SELECT 1 FROM fulfilment_groups g WHERE g.order_id = orders.id)").
+		Find(&orders).Error
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			groups := map[uint]*domain.FulfilmentGroup{}
+			var sellers []uint
+			for _, item := range order.Items {
+				g, ok := groups[item.SellerId]
+				if !ok {
+					zero := money.Zero(item.Price.Currency)
+					g = &domain.FulfilmentGroup{
+						OrderId:          order.ID,
+						SellerId:         item.SellerId,
+						Status:           order.Status,
+						Subtotal:         zero,
+						Discount:         zero,
+						Shipping:         zero,
+						ShippingDiscount: zero,
+						Tax:              zero,
+					}
+					groups[item.SellerId] = g
+					sellers = append(sellers, item.SellerId)
+				}
+				g.Subtotal = g.Subtotal.Add(item.Price.Mul(int64(item.Qty)))
+				g.Discount = g.Discount.Add(item.Discount)
+			}
+			for _, sellerId := range sellers {
+				g := groups[sellerId]
+				g.Amount = g.Subtotal.Sub(g.Discount)
+				if err := tx.Create(g).Error; err != nil {
+					return err
+				}
+				err := tx.Model(&domain.OrderItem{}).
+					Where("order_id = ? AND seller_id = ?", order.ID, sellerId).
+					Update("fulfilment_group_id", g.ID).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &orderRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	SortByCreatedAt = "created_at"
+	SortByPrice     = "price"
+	SortByName      = "name"
+)
+
+// ProductQuery describes a filtered, sorted and paginated product listing.
+// Zero values mean "no filter". When Cursor is set, Page is ignored and the
+// listing continues after the cursor position (keyset pagination).
+// Prices are compared in minor units of the default currency, see Product.SortPrice.
+type ProductQuery struct {
+	CategoryIds []uint
+	SellerId    uint
+	MinPrice    *int64
+	MaxPrice    *int64
+	InStockOnly bool
+
+	SortBy   string
+	SortDesc bool
+
+	Page   int
+	Limit  int
+	Cursor string
+}
+
+// ProductPage is one page of a ProductQuery result
+type ProductPage struct {
+	Products   []*domain.Product
+	Total      int64
+	NextCursor string
+}
+
+// productCursor is the position of the last row of a page, encoded opaquely for clients
+type productCursor struct {
+	Value interface{} `json:"v"`
+	ID    uint        `json:"id"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func (q ProductQuery) sortColumn() string {
+	switch q.SortBy {
+	case SortByPrice:
+		return "sort_price"
+	case SortByName:
+		return q.SortBy
+	default:
+		return SortByCreatedAt
+	}
+}
+
+// applyFilters adds the filtering conditions shared by the count and page queries
+func (q ProductQuery) applyFilters(tx *gorm.DB) *gorm.DB {
+	if len(q.CategoryIds) > 0 {
+		tx = tx.Where("products.category_id IN ?", q.CategoryIds)
+	}
+	if q.SellerId > 0 {
+		tx = tx.Where("products.user_id = ?", q.SellerId)
+	}
+	if q.MinPrice != nil {
+		tx = tx.Where("products.sort_price >= ?", *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		tx = tx.Where("products.sort_price <= ?", *q.MaxPrice)
+	}
+	if q.InStockOnly {
+		tx = tx.Where("products.stock > 0")
+	}
+	return tx
+}
+
+// applyPage adds ordering and either the keyset cursor or offset pagination
+func (q ProductQuery) applyPage(tx *gorm.DB) (*gorm.DB, error) {
+	col := "products." + q.sortColumn()
+	dir, cmp := "ASC", ">"
+	if q.SortDesc {
+		dir, cmp = "DESC", "<"
+	}
+
+	if q.Cursor != "" {
+		cur, err := decodeProductCursor(q.Cursor, q.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(fmt.Sprintf("(%s, products.id) %s (?, ?)", col, cmp), cur.Value, cur.ID)
+	} else if q.Page > 1 {
+		tx = tx.Offset((q.Page - 1) * q.Limit)
+	}
+
+	return tx.Order(fmt.Sprintf("%s %s, products.id %s", col, dir, dir)).Limit(q.Limit), nil
+}
+
+// applyOffset adds plain offset pagination, for listings that cannot use a keyset cursor
+func (q ProductQuery) applyOffset(tx *gorm.DB) *gorm.DB {
+	if q.Page > 1 {
+		tx = tx.Offset((q.Page - 1) * q.Limit)
+	}
+	return tx.Limit(q.Limit)
+}
+
+func (q ProductQuery) nextCursor(products []*domain.Product) string {
+	if len(products) < q.Limit || len(products) == 0 {
+		return ""
+	}
+	last := products[len(products)-1]
+
+	cur := productCursor{ID: last.ID}
+	switch q.SortBy {
+	case SortByPrice:
+		cur.Value = last.SortPrice
+	case SortByName:
+		cur.Value = last.Name
+	default:
+		cur.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	}
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeProductCursor(s string, sortBy string) (productCursor, error) {
+	var cur productCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, ErrInvalidCursor
+	}
+	if err = json.Unmarshal(b, &cur); err != nil || cur.ID == 0 {
+		return cur, ErrInvalidCursor
+	}
+
+	// restore the typed value so the database compares it against the right column type
+	switch v := cur.Value.(type) {
+	case float64:
+		if sortBy != SortByPrice {
+			return cur, ErrInvalidCursor
+		}
+		cur.Value = int64(v)
+	case string:
+		if sortBy == SortByPrice {
+			return cur, ErrInvalidCursor
+		}
+		if sortBy != SortByName {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return cur, ErrInvalidCursor
+			}
+			cur.Value = t
+		}
+	default:
+		return cur, ErrInvalidCursor
+	}
+	return cur, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"html"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// minimum trigram similarity for a misspelt term to still match a product name
+const fuzzyThreshold = 0.3
+
+// ts_headline marks matches with these private use characters instead of HTML tags, so the seller's
+// text can be escaped before the marks are turned into <mark> tags
+const (
+	markStart = "\uE000"
+	markStop  = "\uE001"
+)
+
+var highlightMarks = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// ProductSearch is a full-text search narrowed by the regular listing filters.
+// Sorting and cursor fields of Filter are ignored, results are ordered by relevance.
+type ProductSearch struct {
+	Terms  string
+	Filter ProductQuery
+}
+
+// ProductSearchHit is a product with its relevance and highlighted fragments.
+// The fragments are HTML escaped, matches are wrapped in <mark> tags.
+type ProductSearchHit struct {
+	domain.Product
+	Rank          float64 `json:"rank"`
+	NameHighlight string  `json:"name_highlight"`
+	Snippet       string  `json:"snippet"`
+}
+
+type ProductSearchPage struct {
+	Hits  []*ProductSearchHit
+	Total int64
+	// Fuzzy is true when no product matched the full-text query and the hits come from trigram similarity
+	Fuzzy bool
+}
+
+var ErrEmptySearch = errors.New("search query is empty")
+
+// SetupProductSearch creates the weighted search vector and the indexes used by product search.
+// It is safe to run on every start.
+func SetupProductSearch(db *gorm.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(description, '')), 'B')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixTsQuery turns free text into a tsquery matching all terms, the last one as a prefix for autocomplete
+func prefixTsQuery(terms string) string {
+	words := strings.FieldsFunc(strings.ToLower(terms), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	words[len(words)-1] += ":*"
+	return strings.Join(words, " & ")
+}
+
+func (c catalogRepository) SearchProducts(s ProductSearch) (*ProductSearchPage, error) {
+	tsQuery := prefixTsQuery(s.Terms)
+	if tsQuery == "" {
+		return nil, ErrEmptySearch
+	}
+
+	page, err := c.fullTextSearch(s, tsQuery)
+	if err != nil {
+		return nil, err
+	}
+	if page.Total > 0 {
+		return page, nil
+	}
+	return c.fuzzySearch(s)
+}
+
+func (c catalogRepository) fullTextSearch(s ProductSearch, tsQuery string) (*ProductSearchPage, error) {
+	base := func() *gorm.DB {
+		tx := c.db.Table("products").
+			Joins("CROSS JOIN to_tsquery('english', ?) AS query", tsQuery).
+			Where("products.search_vector @@ query")
+		return s.Filter.applyFilters(tx)
+	}
+
+	var total int64
+	if err := base().Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not search products")
+	}
+
+	var hits []*ProductSearchHit
+	marks := `StartSel="` + markStart + `", StopSel="` + markStop + `"`
+	err := s.Filter.applyOffset(base()).
+		Select(`products.*,
+			ts_rank_cd(products.search_vector, query) AS rank,
+			ts_headline('english', translate(products.name, ?, ''), query, ?) AS name_highlight,
+			ts_headline('english', translate(coalesce(products.description, ''), ?, ''), query, ?) AS snippet`,
+			markStart+markStop, "HighlightAll=true, "+marks, markStart+markStop, marks+", MaxFragments=2").
+		Order("rank DESC, products.id").
+		Scan(&hits).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not search products")
+	}
+	escapeHighlights(hits)
+
+	return &ProductSearchPage{Hits: hits, Total: total}, nil
+}
+
+// fuzzySearch matches product names by trigram similarity so misspelt queries still find results
+func (c catalogRepository) fuzzySearch(s ProductSearch) (*ProductSearchPage, error) {
+	terms := strings.TrimSpace(s.Terms)
+	base := func() *gorm.DB {
+		tx := c.db.Table("products").
+			Where("similarity(products.name, ?) > ? OR word_similarity(?, products.name) > ?",
+				terms, fuzzyThreshold, terms, fuzzyThreshold)
+		return s.Filter.applyFilters(tx)
+	}
+
+	var total int64
+	if err := base().Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not search products")
+	}
+
+	var hits []*ProductSearchHit
+	err := s.Filter.applyOffset(base()).
+		Select(`products.*,
+			greatest(similarity(products.name, ?), word_similarity(?, products.name)) AS rank,
+			translate(products.name, ?, '') AS name_highlight,
+			left(translate(coalesce(products.description, ''), ?, ''), 160) AS snippet`,
+			terms, terms, markStart+markStop, markStart+markStop).
+		Order("rank DESC, products.id").
+		Scan(&hits).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not search products")
+	}
+
+	escapeHighlights(hits)
+
+	return &ProductSearchPage{Hits: hits, Total: total, Fuzzy: true}, nil
+}
+
+// escapeHighlights makes the seller supplied text safe to render as HTML, only the match marks become tags
+func escapeHighlights(hits []*ProductSearchHit) {
+	for _, hit := range hits {
+		hit.NameHighlight = highlightMarks.Replace(html.EscapeString(hit.NameHighlight))
+		hit.Snippet = highlightMarks.Replace(html.EscapeString(hit.Snippet))
+	}
+}
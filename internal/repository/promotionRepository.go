@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"log"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrPromotionUsedUp = errors.New("coupon has reached its usage limit")
+
+type PromotionRepository interface {
+	CreatePromotion(p *domain.Promotion) error
+	FindPromotions(createdBy uint) ([]*domain.Promotion, error)
+	FindPromotionByID(id uint) (*domain.Promotion, error)
+	FindPromotionByCode(code string) (*domain.Promotion, error)
+	UpdatePromotion(p *domain.Promotion) error
+	DeletePromotion(id uint) error
+	CountRedemptions(promotionId uint, userId uint) (int64, error)
+
+	FindCartCoupon(userId uint) (*domain.CartCoupon, error)
+	SaveCartCoupon(c *domain.CartCoupon) error
+	DeleteCartCoupon(userId uint) error
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+func (r promotionRepository) CreatePromotion(p *domain.Promotion) error {
+	err := r.db.Create(p).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not create promotion")
+	}
+	return nil
+}
+
+// FindPromotions lists the promotions created by the user, or every promotion when createdBy is 0
+func (r promotionRepository) FindPromotions(createdBy uint) ([]*domain.Promotion, error) {
+	var promotions []*domain.Promotion
+	tx := r.db.Order("id desc")
+	if createdBy > 0 {
+		tx = tx.Where("created_by = ?", createdBy)
+	}
+	err := tx.Find(&promotions).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch promotions")
+	}
+	return promotions, nil
+}
+
+func (r promotionRepository) FindPromotionByID(id uint) (*domain.Promotion, error) {
+	var promotion *domain.Promotion
+	err := r.db.First(&promotion, id).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("promotion does not exist")
+	}
+	return promotion, nil
+}
+
+func (r promotionRepository) FindPromotionByCode(code string) (*domain.Promotion, error) {
+	var promotion *domain.Promotion
+	err := r.db.Where("code = ?", code).First(&promotion).Error
+	if err != nil {
+		return nil, errors.New("coupon does not exist")
+	}
+	return promotion, nil
+}
+
+func (r promotionRepository) UpdatePromotion(p *domain.Promotion) error {
+	err := r.db.Save(p).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not update promotion")
+	}
+	return nil
+}
+
+// DeletePromotion removes the promotion and any cart it is applied to.
+// Redemptions are kept, orders carry their own copy of the code and discounts.
+func (r promotionRepository) DeletePromotion(id uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("promotion_id = ?", id).Delete(&domain.CartCoupon{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Promotion{}, id).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not delete promotion")
+	}
+	return nil
+}
+
+func (r promotionRepository) CountRedemptions(promotionId uint, userId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.PromotionRedemption{}).
+		Where("promotion_id = ? AND user_id = ?", promotionId, userId).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return 0, errors.New("could not count coupon usage")
+	}
+	return count, nil
+}
+
+func (r promotionRepository) FindCartCoupon(userId uint) (*domain.CartCoupon, error) {
+	var coupon *domain.CartCoupon
+	err := r.db.Where("user_id = ?", userId).First(&coupon).Error
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// SaveCartCoupon applies the coupon to the buyer's cart, replacing any coupon applied before
+func (r promotionRepository) SaveCartCoupon(c *domain.CartCoupon) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"promotion_id", "code", "updated_at"}),
+	}).Create(c).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not apply coupon")
+	}
+	return nil
+}
+
+func (r promotionRepository) DeleteCartCoupon(userId uint) error {
+	err := r.db.Where("user_id = ?", userId).Delete(&domain.CartCoupon{}).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not remove coupon")
+	}
+	return nil
+}
+
+// redeemPromotion counts the order against the promotion's usage limits inside the order transaction.
+// The promotion row is locked so concurrent checkouts cannot both take the last use.
+func redeemPromotion(tx *gorm.DB, o *domain.Order) error {
+	var promotion domain.Promotion
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, o.PromotionId).Error
+	if err != nil {
+		return ErrPromotionUsedUp
+	}
+	if promotion.UsageLimit > 0 && promotion.UsedCount >= promotion.UsageLimit {
+		return ErrPromotionUsedUp
+	}
+	if promotion.PerUserLimit > 0 {
+		var used int64
+		err = tx.Model(&domain.PromotionRedemption{}).
+			Where("promotion_id = ? AND user_id = ?", promotion.ID, o.UserId).
+			Count(&used).Error
+		if err != nil {
+			return err
+		}
+		if used >= int64(promotion.PerUserLimit) {
+			return ErrPromotionUsedUp
+		}
+	}
+
+	err = tx.Model(&promotion).UpdateColumn("used_count", gorm.Expr("used_count + 1")).Error
+	if err != nil {
+		return err
+	}
+	return tx.Create(&domain.PromotionRedemption{
+		PromotionId: promotion.ID,
+		UserId:      o.UserId,
+		OrderId:     o.ID,
+		Code:        o.CouponCode,
+		Discount:    o.Discount.Add(o.ShippingDiscount),
+	}).Error
+}
+
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{
+		db: db,
+	}
+}
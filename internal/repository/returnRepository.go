@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/pkg/money"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrReturnNotFound      = errors.New("return request does not exist")
+	ErrReturnNotAllowed    = errors.New("items can only be returned once they are delivered")
+	ErrInvalidReturn       = errors.New("return request is not valid")
+	ErrReturnStatus        = errors.New("return request cannot take that step in its current status")
+	ErrInvalidRefundAmount = errors.New("refund amount is more than was paid for the returned items")
+)
+
+// statuses of a fulfilment group in which its items can be returned
+var returnableStatuses = map[string]bool{
+	domain.OrderStatusDelivered:         true,
+	domain.OrderStatusCompleted:         true,
+	domain.OrderStatusPartiallyRefunded: true,
+}
+
+type ReturnRepository interface {
+	CreateReturn(r *domain.ReturnRequest) error
+	FindReturns(userId uint, sellerId uint, status string, page int, limit int) ([]*domain.ReturnRequest, int64, error)
+	FindReturnByID(id uint) (*domain.ReturnRequest, error)
+	CreateReturnPhoto(p *domain.ReturnPhoto) error
+	ReviewReturn(id uint, sellerId uint, approve bool, note string) (*domain.ReturnRequest, error)
+	ReceiveReturn(id uint, sellerId uint, restock bool) (*domain.ReturnRequest, error)
+	RefundReturn(id uint, sellerId uint, amount money.Money, issue func(refund *domain.Refund) error) (*domain.ReturnRequest, error)
+}
+
+type returnRepository struct {
+	db *gorm.DB
+}
+
+// CreateReturn opens a return for the buyer's order item. The quantity is checked against what was
+// delivered less what is already being returned, rejected returns do not count.
+func (r returnRepository) CreateReturn(e *domain.ReturnRequest) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, e.OrderId)
+		if err != nil || order.UserId != e.UserId {
+			return ErrReturnNotFound
+		}
+		group, item := findOrderItem(order, e.OrderItemId)
+		if item == nil {
+			return errors.Wrapf(ErrInvalidReturn, "order item %d is not part of the order", e.OrderItemId)
+		}
+		if !returnableStatuses[group.Status] {
+			return errors.Wrap(ErrReturnNotAllowed, group.Status)
+		}
+
+		var returned int64
+		err = tx.Model(&domain.ReturnRequest{}).
+			Where("order_item_id = ? AND status <> ?", item.ID, domain.ReturnStatusRejected).
+			Select("COALESCE(SUM(qty), 0)").
+			Scan(&returned).Error
+		if err != nil {
+			return err
+		}
+		left := int64(item.Qty) - int64(item.CancelledQty) - returned
+		if int64(e.Qty) > left {
+			return errors.Wrapf(ErrInvalidReturn, "order item %d has %d left to return", item.ID, max(left, 0))
+		}
+
+		e.ID = 0
+		e.FulfilmentGroupId = group.ID
+		e.SellerId = group.SellerId
+		e.Status = domain.ReturnStatusRequested
+		if err = tx.Omit("Photos").Create(e).Error; err != nil {
+			return err
+		}
+		return noteGroupEvent(tx, group, e.UserId, fmt.Sprintf("return %d requested for %d x %s", e.ID, e.Qty, item.Name))
+	})
+	return returnError(err, "could not create return request")
+}
+
+// FindReturns lists returns newest first, of the buyer when userId is set and of the seller when sellerId is set
+func (r returnRepository) FindReturns(userId uint, sellerId uint, status string, page int, limit int) ([]*domain.ReturnRequest, int64, error) {
+	var total int64
+	var returns []*domain.ReturnRequest
+
+	tx := r.db.Model(&domain.ReturnRequest{})
+	if userId > 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if sellerId > 0 {
+		tx = tx.Where("seller_id = ?", sellerId)
+	}
+	if len(status) > 0 {
+		tx = tx.Where("status = ?", status)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("could not fetch return requests")
+	}
+	err := tx.Preload("Photos").Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&returns).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("could not fetch return requests")
+	}
+	return returns, total, nil
+}
+
+func (r returnRepository) FindReturnByID(id uint) (*domain.ReturnRequest, error) {
+	var e domain.ReturnRequest
+	err := r.db.Preload("Photos").First(&e, id).Error
+	if err != nil {
+		return nil, ErrReturnNotFound
+	}
+	return &e, nil
+}
+
+func (r returnRepository) CreateReturnPhoto(p *domain.ReturnPhoto) error {
+	err := r.db.Create(p).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not save return photo")
+	}
+	return nil
+}
+
+// ReviewReturn approves or rejects a requested return with the seller's note
+func (r returnRepository) ReviewReturn(id uint, sellerId uint, approve bool, note string) (*domain.ReturnRequest, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		e, order, err := lockReturn(tx, id, sellerId)
+		if err != nil {
+			return err
+		}
+		group, _ := findOrderItem(order, e.OrderItemId)
+		if e.Status != domain.ReturnStatusRequested {
+			return errors.Wrap(ErrReturnStatus, e.Status)
+		}
+		e.Status = domain.ReturnStatusRejected
+		if approve {
+			e.Status = domain.ReturnStatusApproved
+		}
+		e.SellerNote = note
+		err = tx.Model(e).Updates(map[string]interface{}{"status": e.Status, "seller_note": e.SellerNote}).Error
+		if err != nil {
+			return err
+		}
+		return noteGroupEvent(tx, group, sellerId, fmt.Sprintf("return %d %s", e.ID, e.Status))
+	})
+	if err = returnError(err, "could not update return request"); err != nil {
+		return nil, err
+	}
+	return r.FindReturnByID(id)
+}
+
+// ReceiveReturn records that the approved items came back, restock puts them back on sale
+func (r returnRepository) ReceiveReturn(id uint, sellerId uint, restock bool) (*domain.ReturnRequest, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		e, order, err := lockReturn(tx, id, sellerId)
+		if err != nil {
+			return err
+		}
+		group, item := findOrderItem(order, e.OrderItemId)
+		if e.Status != domain.ReturnStatusApproved {
+			return errors.Wrap(ErrReturnStatus, e.Status)
+		}
+		if restock {
+			variants, err := lockVariants(tx, []uint{item.VariantId})
+			if err != nil {
+				return err
+			}
+			if err = lockProducts(tx, []uint{item.ProductId}); err != nil {
+				return err
+			}
+			// variants deleted since the order was placed have nothing to restock
+			if v, ok := variants[item.VariantId]; ok {
+				err = moveStock(tx, v, domain.StockMovement{
+					Delta:   int(e.Qty),
+					Reason:  domain.StockReasonReturn,
+					ActorId: sellerId,
+					OrderId: e.OrderId,
+					Note:    fmt.Sprintf("return %d", e.ID),
+				})
+				if err != nil {
+					return err
+				}
+				e.Restocked = true
+			}
+		}
+		e.Status = domain.ReturnStatusReceived
+		err = tx.Model(e).Updates(map[string]interface{}{"status": e.Status, "restocked": e.Restocked}).Error
+		if err != nil {
+			return err
+		}
+		return noteGroupEvent(tx, group, sellerId, fmt.Sprintf("return %d received", e.ID))
+	})
+	if err = returnError(err, "could not update return request"); err != nil {
+		return nil, err
+	}
+	return r.FindReturnByID(id)
+}
+
+// RefundReturn refunds a received return through issue, an unset or zero amount refunds what was
+// paid for the returned items. The amount is in the currency the order was paid in. The return is
+// locked while issue runs and only marked refunded once the refund was issued, a failed refund is
+// recorded and leaves the return received so the seller can try again, ErrRefundFailed is returned.
+// The group becomes refunded once everything it sold has been refunded through returns,
+// partially refunded until then.
+func (r returnRepository) RefundReturn(id uint, sellerId uint, amount money.Money, issue func(refund *domain.Refund) error) (*domain.ReturnRequest, error) {
+	var issueErr error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		e, order, err := lockReturn(tx, id, sellerId)
+		if err != nil {
+			return err
+		}
+		group, item := findOrderItem(order, e.OrderItemId)
+		if e.Status != domain.ReturnStatusReceived {
+			return errors.Wrap(ErrReturnStatus, e.Status)
+		}
+		paid := linesRefund(group, map[uint]uint{item.ID: e.Qty})
+		if !amount.IsSet() || amount.IsZero() {
+			amount = paid
+		}
+		if amount.Currency != paid.Currency {
+			return errors.Wrapf(ErrInvalidRefundAmount, "the order was paid in %s", paid.Currency)
+		}
+		if amount.Amount <= 0 || paid.Less(amount) {
+			return errors.Wrapf(ErrInvalidRefundAmount, "at most %s", paid)
+		}
+
+		refund := &domain.Refund{
+			OrderId:   e.OrderId,
+			GroupId:   group.ID,
+			ReturnId:  e.ID,
+			PaymentId: order.PaymentId,
+			Amount:    amount,
+			Reason:    fmt.Sprintf("return %d: %s", e.ID, e.Reason),
+			Status:    domain.RefundStatusPending,
+		}
+		if err = tx.Create(refund).Error; err != nil {
+			return err
+		}
+		issueErr = issue(refund)
+		if err = updateRefund(tx, refund); err != nil || issueErr != nil {
+			return err
+		}
+
+		e.Status = domain.ReturnStatusRefunded
+		e.RefundedAmount = amount
+		err = tx.Model(e).Updates(map[string]interface{}{
+			"status":                   e.Status,
+			"refunded_amount_minor":    e.RefundedAmount.Amount,
+			"refunded_amount_currency": e.RefundedAmount.Currency,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		to, err := refundedStatus(tx, group)
+		if err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("return %d refunded %s", e.ID, amount)
+		if err = transitionGroup(tx, group, to, sellerId, reason); err != nil {
+			return err
+		}
+		return syncOrderStatus(tx, order, sellerId, reason)
+	})
+	if err = returnError(err, "could not refund return request"); err != nil {
+		return nil, err
+	}
+	if issueErr != nil {
+		return nil, errors.Wrap(ErrRefundFailed, issueErr.Error())
+	}
+	return r.FindReturnByID(id)
+}
+
+// lockReturn locks the order of the seller's return, then the return itself
+func lockReturn(tx *gorm.DB, id uint, sellerId uint) (*domain.ReturnRequest, *domain.Order, error) {
+	var e domain.ReturnRequest
+	err := tx.Where("id = ? AND seller_id = ?", id, sellerId).First(&e).Error
+	if err != nil {
+		return nil, nil, ErrReturnNotFound
+	}
+	order, err := lockOrder(tx, e.OrderId)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&e, e.ID).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, item := findOrderItem(order, e.OrderItemId); item == nil {
+		return nil, nil, ErrReturnNotFound
+	}
+	return &e, order, nil
+}
+
+func findOrderItem(order *domain.Order, itemId uint) (*domain.FulfilmentGroup, *domain.OrderItem) {
+	for i := range order.Groups {
+		for j := range order.Groups[i].Items {
+			if order.Groups[i].Items[j].ID == itemId {
+				return &order.Groups[i], &order.Groups[i].Items[j]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// refundedStatus compares the return refunds of the group with what was paid for its items that were not cancelled
+func refundedStatus(tx *gorm.DB, group *domain.FulfilmentGroup) (string, error) {
+	var refunded int64
+	err := tx.Model(&domain.Refund{}).
+		Where("group_id = ? AND return_id <> 0 AND status <> ?", group.ID, domain.RefundStatusFailed).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Scan(&refunded).Error
+	if err != nil {
+		return "", err
+	}
+	open := map[uint]uint{}
+	for _, item := range group.Items {
+		if item.Qty > item.CancelledQty {
+			open[item.ID] = item.Qty - item.CancelledQty
+		}
+	}
+	if refunded >= linesRefund(group, open).Amount {
+		return domain.OrderStatusRefunded, nil
+	}
+	return domain.OrderStatusPartiallyRefunded, nil
+}
+
+func returnError(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	for _, known := range []error{ErrReturnNotFound, ErrReturnNotAllowed, ErrInvalidReturn,
+		ErrReturnStatus, ErrInvalidRefundAmount, ErrInvalidOrderTransition} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Printf("db_err: %v", err)
+	return errors.New(msg)
+}
+
+func NewReturnRepository(db *gorm.DB) ReturnRepository {
+	return &returnRepository{db: db}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"log"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ReviewSortNewest  = "newest"
+	ReviewSortHelpful = "helpful"
+)
+
+type ReviewRepository interface {
+	CreateReview(r *domain.Review) error
+	FindReviews(productId uint, sort string, page int, limit int) ([]*domain.Review, int64, error)
+	FindReviewByID(id uint) (*domain.Review, error)
+	FindUserReview(productId uint, userId uint) (*domain.Review, error)
+	UpdateReview(r *domain.Review) error
+	AddHelpfulVote(reviewId uint, userId uint) error
+	HasCompletedPurchase(userId uint, productId uint) (bool, error)
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+// syncProductRating recomputes the rating average and count stored on the product
+func syncProductRating(tx *gorm.DB, productId uint) error {
+	return tx.Exec(`UPDATE products SET
+			rating_avg = COALESCE((SELECT AVG(rating) FROM reviews WHERE product_id = ?), 0),
+			rating_count = (SELECT COUNT(*) FROM reviews WHERE product_id = ?)
+		WHERE id = ?`, productId, productId, productId).Error
+}
+
+func (r reviewRepository) CreateReview(e *domain.Review) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(e).Error; err != nil {
+			return err
+		}
+		return syncProductRating(tx, e.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not create review")
+	}
+	return nil
+}
+
+func (r reviewRepository) FindReviews(productId uint, sort string, page int, limit int) ([]*domain.Review, int64, error) {
+	var total int64
+	var reviews []*domain.Review
+
+	tx := r.db.Model(&domain.Review{}).Where("product_id = ?", productId)
+	if err := tx.Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("could not fetch reviews")
+	}
+
+	order := "created_at DESC, id DESC"
+	if sort == ReviewSortHelpful {
+		order = "helpful_count DESC, created_at DESC, id DESC"
+	}
+	err := tx.Order(order).Offset((page - 1) * limit).Limit(limit).Find(&reviews).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("could not fetch reviews")
+	}
+	return reviews, total, nil
+}
+
+func (r reviewRepository) FindReviewByID(id uint) (*domain.Review, error) {
+	var review domain.Review
+	err := r.db.First(&review, id).Error
+	if err != nil {
+		return nil, errors.New("review does not exist")
+	}
+	return &review, nil
+}
+
+func (r reviewRepository) FindUserReview(productId uint, userId uint) (*domain.Review, error) {
+	var review domain.Review
+	err := r.db.Where("product_id = ? AND user_id = ?", productId, userId).First(&review).Error
+	if err != nil {
+		return nil, errors.New("review does not exist")
+	}
+	return &review, nil
+}
+
+func (r reviewRepository) UpdateReview(e *domain.Review) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(e).Error; err != nil {
+			return err
+		}
+		return syncProductRating(tx, e.ProductId)
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not update review")
+	}
+	return nil
+}
+
+// AddHelpfulVote counts the user's vote once, repeated votes are ignored
+func (r reviewRepository) AddHelpfulVote(reviewId uint, userId uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&domain.ReviewVote{ReviewId: reviewId, UserId: userId})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Model(&domain.Review{}).
+			Where("id = ?", reviewId).
+			Update("helpful_count", gorm.Expr("helpful_count + 1")).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not record vote")
+	}
+	return nil
+}
+
+// HasCompletedPurchase reports whether the user bought the product in a fulfilment group that has been completed
+func (r reviewRepository) HasCompletedPurchase(userId uint, productId uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("JOIN fulfilment_groups ON fulfilment_groups.id = order_items.fulfilment_group_id").
+		Where("orders.user_id = ? AND fulfilment_groups.status = ? AND order_items.product_id = ?",
+			userId, domain.OrderStatusCompleted, productId).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return false, errors.New("could not check purchase")
+	}
+	return count > 0, nil
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoSellerItems      = errors.New("order has no items sold by you")
+	ErrShipmentNotFound   = errors.New("shipment does not exist")
+	ErrShipmentNotAllowed = errors.New("order cannot be shipped in its current status")
+	ErrInvalidShipment    = errors.New("shipment items are not valid")
+	ErrShipmentStatus     = errors.New("shipment cannot move back to an earlier status")
+)
+
+// progress of a parcel, shipments only move forward
+var shipmentRank = map[string]int{
+	domain.ShipmentStatusShipped:   0,
+	domain.ShipmentStatusInTransit: 1,
+	domain.ShipmentStatusDelivered: 2,
+}
+
+// CreateShipment records a parcel for the seller's fulfilment group of the order. Without items it ships
+// everything not shipped yet. A paid group moves to processing first and to shipped once all its items shipped.
+func (r orderRepository) CreateShipment(orderId uint, s *domain.Shipment) (*domain.Order, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, orderId)
+		if err != nil {
+			return err
+		}
+		group := findGroup(order, s.SellerId)
+		if group == nil {
+			return ErrNoSellerItems
+		}
+		reason := "shipment " + s.TrackingNumber
+		if group.Status == domain.OrderStatusPaid {
+			if err = transitionGroup(tx, group, domain.OrderStatusProcessing, s.SellerId, reason); err != nil {
+				return err
+			}
+		}
+		if group.Status != domain.OrderStatusProcessing {
+			return errors.Wrap(ErrShipmentNotAllowed, group.Status)
+		}
+
+		remaining, err := unshippedQuantities(tx, group)
+		if err != nil {
+			return err
+		}
+		if len(s.Items) == 0 {
+			for _, item := range group.Items {
+				if remaining[item.ID] > 0 {
+					s.Items = append(s.Items, domain.ShipmentItem{OrderItemId: item.ID, Qty: remaining[item.ID]})
+				}
+			}
+			if len(s.Items) == 0 {
+				return errors.Wrap(ErrInvalidShipment, "every item is already shipped")
+			}
+		}
+		for _, item := range s.Items {
+			left, ok := remaining[item.OrderItemId]
+			if !ok {
+				return errors.Wrapf(ErrInvalidShipment, "order item %d is not part of your order", item.OrderItemId)
+			}
+			if item.Qty == 0 || item.Qty > left {
+				return errors.Wrapf(ErrInvalidShipment, "order item %d has %d left to ship", item.OrderItemId, left)
+			}
+			remaining[item.OrderItemId] = left - item.Qty
+		}
+
+		s.ID = 0
+		s.OrderId = order.ID
+		s.FulfilmentGroupId = group.ID
+		s.Status = domain.ShipmentStatusShipped
+		s.ShippedAt = time.Now()
+		if err = tx.Create(s).Error; err != nil {
+			return err
+		}
+
+		if allShipped(remaining) {
+			if err = transitionGroup(tx, group, domain.OrderStatusShipped, s.SellerId, reason); err != nil {
+				return err
+			}
+		}
+		return syncOrderStatus(tx, order, s.SellerId, reason)
+	})
+	if err != nil {
+		return nil, shipmentError(err)
+	}
+	return r.FindOrderByID(orderId)
+}
+
+// UpdateShipment changes the carrier details and moves the parcel forward. The group is delivered
+// once it is fully shipped and every one of its parcels is delivered.
+func (r orderRepository) UpdateShipment(update domain.Shipment) (*domain.Order, error) {
+	var orderId uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current domain.Shipment
+		err := tx.Where("id = ? AND seller_id = ?", update.ID, update.SellerId).First(&current).Error
+		if err != nil {
+			return ErrShipmentNotFound
+		}
+		orderId = current.OrderId
+		order, err := lockOrder(tx, orderId)
+		if err != nil {
+			return err
+		}
+		if err = tx.First(&current, current.ID).Error; err != nil {
+			return err
+		}
+
+		if len(update.Carrier) > 0 {
+			current.Carrier = update.Carrier
+		}
+		if len(update.TrackingNumber) > 0 {
+			current.TrackingNumber = update.TrackingNumber
+		}
+		if len(update.TrackingUrl) > 0 {
+			current.TrackingUrl = update.TrackingUrl
+		}
+		if len(update.Status) > 0 && update.Status != current.Status {
+			rank, ok := shipmentRank[update.Status]
+			if !ok || rank < shipmentRank[current.Status] {
+				return ErrShipmentStatus
+			}
+			current.Status = update.Status
+			if current.Status == domain.ShipmentStatusDelivered {
+				now := time.Now()
+				current.DeliveredAt = &now
+			}
+		}
+		if err = tx.Omit("Items").Save(&current).Error; err != nil {
+			return err
+		}
+
+		group := findGroup(order, current.SellerId)
+		if group == nil || group.Status != domain.OrderStatusShipped {
+			return nil
+		}
+		var undelivered int64
+		err = tx.Model(&domain.Shipment{}).
+			Where("fulfilment_group_id = ? AND status <> ?", group.ID, domain.ShipmentStatusDelivered).
+			Count(&undelivered).Error
+		if err != nil || undelivered > 0 {
+			return err
+		}
+		reason := "shipment " + current.TrackingNumber + " delivered"
+		if err = transitionGroup(tx, group, domain.OrderStatusDelivered, 0, reason); err != nil {
+			return err
+		}
+		return syncOrderStatus(tx, order, 0, reason)
+	})
+	if err != nil {
+		return nil, shipmentError(err)
+	}
+	return r.FindOrderByID(orderId)
+}
+
+func findGroup(order *domain.Order, sellerId uint) *domain.FulfilmentGroup {
+	for i := range order.Groups {
+		if order.Groups[i].SellerId == sellerId {
+			return &order.Groups[i]
+		}
+	}
+	return nil
+}
+
+// unshippedQuantities returns, for every item of the group, the quantity neither cancelled nor in a shipment yet
+func unshippedQuantities(tx *gorm.DB, group *domain.FulfilmentGroup) (map[uint]uint, error) {
+	var shipped []struct {
+		OrderItemId uint
+		Qty         uint
+	}
+	err := tx.Table("shipment_items").
+		Select("shipment_items.order_item_id, SUM(shipment_items.qty) AS qty").
+		Joins("JOIN shipments ON shipments.id = shipment_items.shipment_id").
+		Where("shipments.fulfilment_group_id = ?", group.ID).
+		Group("shipment_items.order_item_id").
+		Scan(&shipped).Error
+	if err != nil {
+		return nil, err
+	}
+	done := map[uint]uint{}
+	for _, s := range shipped {
+		done[s.OrderItemId] = s.Qty
+	}
+	remaining := map[uint]uint{}
+	for _, item := range group.Items {
+		if item.Qty > item.CancelledQty+done[item.ID] {
+			remaining[item.ID] = item.Qty - item.CancelledQty - done[item.ID]
+		} else {
+			remaining[item.ID] = 0
+		}
+	}
+	return remaining, nil
+}
+
+func allShipped(remaining map[uint]uint) bool {
+	for _, qty := range remaining {
+		if qty > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func shipmentError(err error) error {
+	for _, known := range []error{ErrNoSellerItems, ErrShipmentNotFound, ErrShipmentNotAllowed,
+		ErrInvalidShipment, ErrShipmentStatus, ErrInvalidOrderTransition} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	log.Printf("error on saving shipment %v", err)
+	return errors.New("failed to save shipment")
+}
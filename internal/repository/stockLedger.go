@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"log"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// StockDrift compares a variant's stock with the stock recomputed from its ledger
+type StockDrift struct {
+	VariantId   uint   `json:"variant_id"`
+	Sku         string `json:"sku"`
+	Stock       uint   `json:"stock"`
+	LedgerStock int    `json:"ledger_stock"`
+	Drift       int    `json:"drift"` // stock minus ledger stock, 0 when they agree
+}
+
+// BackfillOpeningBalances records the stock of variants that predate the ledger as an opening balance.
+// It is safe to run on every start.
+func BackfillOpeningBalances(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO stock_movements (product_id, variant_id, delta, balance, reason, actor_id, order_id, note)
+		SELECT v.product_id, v.id, v.stock, v.stock, ?, 0, 0, '' FROM product_variants v
+		WHERE v.stock > 0 AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.variant_id = v.id)`,
+		domain.StockReasonOpening).Error
+}
+
+// FindStockMovements returns a page of the product's stock ledger, newest first
+func (c catalogRepository) FindStockMovements(productId uint, page int, limit int) ([]*domain.StockMovement, int64, error) {
+	var total int64
+	var movements []*domain.StockMovement
+
+	tx := c.db.Model(&domain.StockMovement{}).Where("product_id = ?", productId)
+	if err := tx.Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("could not fetch stock history")
+	}
+	err := tx.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&movements).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("could not fetch stock history")
+	}
+	return movements, total, nil
+}
+
+// ReconcileStock recomputes the stock of each variant of the product from the ledger
+func (c catalogRepository) ReconcileStock(productId uint) ([]StockDrift, error) {
+	var drifts []StockDrift
+	err := c.db.Raw(`
+		SELECT v.id AS variant_id, v.sku, v.stock,
+			COALESCE(SUM(m.delta), 0) AS ledger_stock,
+			v.stock - COALESCE(SUM(m.delta), 0) AS drift
+		FROM product_variants v
+		LEFT JOIN stock_movements m ON m.variant_id = v.id
+		WHERE v.product_id = ?
+		GROUP BY v.id, v.sku, v.stock
+		ORDER BY v.id`, productId).Scan(&drifts).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not reconcile stock")
+	}
+	return drifts, nil
+}
@@ -3,55 +3,278 @@ package repository
 import (
 	"ecommerce-app/internal/domain"
 	"ecommerce-app/internal/dto"
+	"ecommerce-app/pkg/money"
+	"log"
+	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionRepository interface {
 	CreatePayment(payment *domain.Payment) error
-	FindOrders(uId uint) ([]domain.OrderItem, error)
-	FindOrderById(uId uint, id uint) (dto.SellerOrderDetails, error)
+	ApplyPayment(id uint, update func(p *domain.Payment) bool, reason string) (*domain.Payment, bool, error)
+	FindPaymentById(id uint) (*domain.Payment, error)
+	FindOrderPayments(orderId uint) ([]domain.Payment, error)
+	FindPaymentByIntent(provider string, intentId string) (*domain.Payment, error)
+	CreateRefund(refund *domain.Refund) error
+
+	// Webhook related methods
+	ProcessPaymentEvent(e *domain.PaymentEvent, apply func() error) error
+	FindOrders(sellerId uint, status string, page int, limit int) ([]dto.SellerOrderDetails, int64, error)
+	FindOrderById(sellerId uint, id uint) (dto.SellerOrderDetails, error)
 }
 
 type transactionStorage struct {
 	db *gorm.DB
 }
 
+// sellerOrderRow is one order item joined to its order, fulfilment group, buyer and the buyer's address
+type sellerOrderRow struct {
+	OrderId           uint
+	OrderRefNumber    int
+	FulfilmentGroupId uint
+	OrderStatus       string
+	CreatedAt         time.Time
+	OrderItemId       uint
+	ProductId         uint
+	Sku               string
+	Name              string
+	ImageUrl          string
+	Price             money.Money `gorm:"embedded;embeddedPrefix:seller_price_"`
+	Qty               uint
+	FirstName         string
+	LastName          string
+	Email             string
+	Phone             string
+	AddressLine1      string
+	AddressLine2      string
+	City              string
+	Postcode          string
+	Country           string
+}
+
+func (row sellerOrderRow) details() dto.SellerOrderDetails {
+	var address []string
+	for _, part := range []string{row.AddressLine1, row.AddressLine2, row.City, row.Postcode, row.Country} {
+		if len(part) > 0 {
+			address = append(address, part)
+		}
+	}
+	return dto.SellerOrderDetails{
+		OrderId:           row.OrderId,
+		OrderRefNumber:    row.OrderRefNumber,
+		FulfilmentGroupId: row.FulfilmentGroupId,
+		OrderStatus:       row.OrderStatus,
+		CreatedAt:         row.CreatedAt.Format(time.RFC3339),
+		OrderItemId:       row.OrderItemId,
+		ProductId:         row.ProductId,
+		Sku:               row.Sku,
+		Name:              row.Name,
+		ImageUrl:          row.ImageUrl,
+		Price:             row.Price,
+		Qty:               row.Qty,
+		CustomerName:      strings.TrimSpace(row.FirstName + " " + row.LastName),
+		CustomerEmail:     row.Email,
+		CustomerPhone:     row.Phone,
+		CustomerAddress:   strings.Join(address, ", "),
+	}
+}
+
+const sellerOrderColumns = `orders.id AS order_id, orders.order_ref_number, order_items.fulfilment_group_id,
+	fulfilment_groups.status AS order_status, orders.created_at,
+	order_items.id AS order_item_id, order_items.product_id, order_items.sku, order_items.name,
+	order_items.image_url, order_items.seller_price_minor, order_items.seller_price_currency, order_items.qty,
+	users.first_name, users.last_name, users.email, users.phone,
+	addresses.address_line1, addresses.address_line2, addresses.city, addresses.postcode, addresses.country`
+
+// sellerOrderItems joins the order items sold by the seller to their order, fulfilment group and buyer
+func (t *transactionStorage) sellerOrderItems(sellerId uint) *gorm.DB {
+	return t.db.Table("order_items").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("JOIN fulfilment_groups ON fulfilment_groups.id = order_items.fulfilment_group_id").
+		Joins("JOIN users ON users.id = orders.user_id").
+		Joins("LEFT JOIN addresses ON addresses.user_id = users.id").
+		Where("order_items.seller_id = ?", sellerId)
+}
 
 func (t *transactionStorage) CreatePayment(payment *domain.Payment) error {
- err := t.db.Create(payment).Error
- if err != nil {
-	return err
- }
- return nil
+	err := t.db.Create(payment).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not save payment")
+	}
+	return nil
+}
+
+// ApplyPayment locks the payment, lets update change it and saves it. When update reports the payment
+// has just succeeded the order is marked paid by it in the same transaction, so concurrent updates of the
+// payment apply once. unneeded is true when the order was no longer awaiting payment and is not paid by
+// this payment, the caller refunds it.
+func (t *transactionStorage) ApplyPayment(id uint, update func(p *domain.Payment) bool, reason string) (*domain.Payment, bool, error) {
+	var payment domain.Payment
+	unneeded := false
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error
+		if err != nil {
+			return err
+		}
+		succeeded := update(&payment)
+		if err = tx.Save(&payment).Error; err != nil {
+			return err
+		}
+		if !succeeded {
+			return nil
+		}
+
+		order, err := lockOrder(tx, payment.OrderId)
+		if err != nil {
+			return err
+		}
+		if order.PaymentId == payment.PaymentId {
+			return nil
+		}
+		err = transitionOrder(tx, order, domain.OrderStatusPaid, 0, reason)
+		if errors.Is(err, ErrInvalidOrderTransition) {
+			unneeded = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(order).Updates(map[string]interface{}{
+			"payment_id":     payment.PaymentId,
+			"transaction_id": payment.ChargeId,
+		}).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, false, errors.New("could not save payment")
+	}
+	return &payment, unneeded, nil
+}
+
+func (t *transactionStorage) FindPaymentById(id uint) (*domain.Payment, error) {
+	var payment domain.Payment
+	err := t.db.First(&payment, id).Error
+	if err != nil {
+		return nil, errors.New("payment does not exist")
+	}
+	return &payment, nil
 }
 
-func (t *transactionStorage) FindOrders(uId uint) ([]domain.OrderItem, error) {
-	var orders []domain.OrderItem
-	err := t.db.Model(&domain.OrderItem{}).
-	Where("user_id = ?", uId).
-	Preload("Product").
-	Preload("Seller").
-	Find(&orders).Error
+func (t *transactionStorage) FindPaymentByIntent(provider string, intentId string) (*domain.Payment, error) {
+	var payment domain.Payment
+	err := t.db.Where("provider = ? AND payment_id = ?", provider, intentId).First(&payment).Error
 	if err != nil {
-		return nil, err
+		return nil, errors.New("payment does not exist")
 	}
-	return orders, nil
-}		
+	return &payment, nil
+}
 
-func (t *transactionStorage) FindOrderById(uId uint, id uint) (dto.SellerOrderDetails, error) {
-	var order dto.SellerOrderDetails
-	err := t.db.Model(&domain.OrderItem{}).
-	Where("user_id = ? AND id = ?", uId, id).
-	Preload("Product").
-	Preload("Seller").
-	First(&order).Error
+// FindOrderPayments lists the payment attempts of the order, oldest first
+func (t *transactionStorage) FindOrderPayments(orderId uint) ([]domain.Payment, error) {
+	var payments []domain.Payment
+	err := t.db.Where("order_id = ?", orderId).Order("id").Find(&payments).Error
 	if err != nil {
-		return dto.SellerOrderDetails{}, err
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch payments")
+	}
+	return payments, nil
+}
+
+// FindOrders lists the seller's order items newest first, optionally only those whose fulfilment group has the status
+func (t *transactionStorage) FindOrders(sellerId uint, status string, page int, limit int) ([]dto.SellerOrderDetails, int64, error) {
+	tx := t.sellerOrderItems(sellerId)
+	if len(status) > 0 {
+		tx = tx.Where("fulfilment_groups.status = ?", status)
 	}
-	return order, nil
-}	
+
+	var total int64
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("could not fetch orders")
+	}
+
+	var rows []sellerOrderRow
+	err := tx.Select(sellerOrderColumns).
+		Order("orders.created_at DESC, order_items.id DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, 0, errors.New("could not fetch orders")
+	}
+
+	orders := make([]dto.SellerOrderDetails, 0, len(rows))
+	for _, row := range rows {
+		orders = append(orders, row.details())
+	}
+	return orders, total, nil
+}
+
+// FindOrderById returns one order item sold by the seller
+func (t *transactionStorage) FindOrderById(sellerId uint, id uint) (dto.SellerOrderDetails, error) {
+	var rows []sellerOrderRow
+	err := t.sellerOrderItems(sellerId).Select(sellerOrderColumns).
+		Where("order_items.id = ?", id).Limit(1).Scan(&rows).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return dto.SellerOrderDetails{}, errors.New("could not fetch order")
+	}
+	if len(rows) == 0 {
+		return dto.SellerOrderDetails{}, errors.New("order does not exist")
+	}
+	return rows[0].details(), nil
+}
+
+func (t *transactionStorage) CreateRefund(refund *domain.Refund) error {
+	err := t.db.Create(refund).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not save refund")
+	}
+	return nil
+}
+
+// ProcessPaymentEvent stores the event unless the provider sent it before and runs apply unless it was
+// already applied. The event row stays locked while apply runs, so a redelivery arriving meanwhile waits
+// and then finds it processed. An apply error is stored on the event, which is applied again next time.
+func (t *transactionStorage) ProcessPaymentEvent(e *domain.PaymentEvent, apply func() error) error {
+	var applyErr error
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(e).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND event_id = ?", e.Provider, e.EventId).First(e).Error
+		if err != nil || e.ProcessedAt != nil {
+			return err
+		}
+
+		applyErr = apply()
+		e.Error = ""
+		if applyErr != nil {
+			e.Error = applyErr.Error()
+		} else {
+			now := time.Now()
+			e.ProcessedAt = &now
+		}
+		return tx.Model(e).Updates(map[string]interface{}{
+			"error":        e.Error,
+			"processed_at": e.ProcessedAt,
+		}).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not record payment event")
+	}
+	return applyErr
+}
 
 func NewTransactionRepository(db *gorm.DB) TransactionRepository {
- return &transactionStorage{db: db}
-}
\ No newline at end of file
+	return &transactionStorage{db: db}
+}
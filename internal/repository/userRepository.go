@@ -17,14 +17,23 @@ type UserRepository interface {
 	CreateBankAccount(e domain.BankAccount) error
 
 	FindCartItems(uId uint) ([]domain.Cart, error)
-	FindCartItem(uId uint, pId uint) (domain.Cart, error)
+	FindCartItem(uId uint, vId uint) (domain.Cart, error)
 	CreateCart(c domain.Cart) error
 	UpdateCart(c domain.Cart) error
 	DeleteCartById(id uint) error
 	DeleteCartItems(uId uint) error
 
+	// Guest cart related methods
+	FindGuestCartItems(token string) ([]domain.Cart, error)
+	FindGuestCartItem(token string, vId uint) (domain.Cart, error)
+	CreateGuestCart(token string, c domain.Cart) error
+	UpdateGuestCart(token string, c domain.Cart) error
+	DeleteGuestCartById(token string, id uint) error
+	DeleteGuestCartItems(token string) error
+	MergeGuestCart(token string, uId uint, lines []domain.Cart) error
+
 	// Order related methods
-	CreateOrder(o domain.Order) error
+	PlaceOrder(o *domain.Order) error
 	FindOrders(uId uint) ([]domain.Order, error)
 	FindOrderById(id uint, uId uint) (domain.Order, error)
 
@@ -60,9 +69,9 @@ func (r userRepository) FindCartItems(uId uint) ([]domain.Cart, error) {
 	return carts, err
 }
 
-func (r userRepository) FindCartItem(uId uint, pId uint) (domain.Cart, error) {
+func (r userRepository) FindCartItem(uId uint, vId uint) (domain.Cart, error) {
 	cartItem := domain.Cart{}
-	err := r.db.Where("user_id = ? AND product_id=?", uId, pId).First(&cartItem).Error
+	err := r.db.Where("user_id = ? AND variant_id=?", uId, vId).First(&cartItem).Error
 	return cartItem, err
 }
 
@@ -90,8 +99,6 @@ func (r userRepository) CreateBankAccount(e domain.BankAccount) error {
 	return r.db.Create(&e).Error
 }
 
-
-
 func (r userRepository) CreateUser(usr domain.User) (domain.User, error) {
 	err := r.db.Create(&usr).Error
 
@@ -116,9 +123,9 @@ func (r userRepository) FindUserById(id uint) (domain.User, error) {
 
 	err := r.db.Preload("Address").
 		Preload("Cart").
-		Preload("Orders").		
+		Preload("Orders").
 		First(&user, id).Error
-		
+
 	if err != nil {
 		log.Printf("Find user error %v", err)
 		return domain.User{}, errors.New("user does not exist")
@@ -137,28 +144,19 @@ func (r userRepository) UpdateUser(id uint, u domain.User) (domain.User, error)
 	return user, nil
 }
 
-func (r userRepository) CreateOrder(o domain.Order) error {
-	err := r.db.Create(&o).Error
+func (r userRepository) FindOrders(uId uint) ([]domain.Order, error) {
+	var orders []domain.Order
+	err := r.db.Where("user_id = ?", uId).Find(&orders).Error
 	if err != nil {
-		log.Printf("error on creating order %v", err)
-		return errors.New("failed to create order")
+		log.Printf("error on finding orders %v", err)
+		return nil, errors.New("failed to find orders")
 	}
-	return nil
-}
-
-func (r userRepository) FindOrders(uId uint) ([]domain.Order, error) {
- var orders []domain.Order
- err := r.db.Where("user_id = ?", uId).Find(&orders).Error
- if err != nil {
-	log.Printf("error on finding orders %v", err)
-	return nil, errors.New("failed to find orders")
- }
- return orders, nil
+	return orders, nil
 }
 
 func (r userRepository) FindOrderById(id uint, uId uint) (domain.Order, error) {
 	var order domain.Order
-	err := r.db.Preload("Items").Where("id = ? AND user_id = ?", id, uId).First(&order).Error
+	err := preloadOrder(r.db).Where("id = ? AND user_id = ?", id, uId).First(&order).Error
 	if err != nil {
 		log.Printf("error on finding order by id %v", err)
 		return domain.Order{}, errors.New("failed to find order")
@@ -169,4 +167,4 @@ func (r userRepository) FindOrderById(id uint, uId uint) (domain.Order, error) {
 // New UserRepository creates a new instance of UserRepository
 func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
-}
\ No newline at end of file
+}
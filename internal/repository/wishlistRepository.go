@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/pkg/money"
+	"log"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WishlistRepository interface {
+	CreateWishlist(w *domain.Wishlist) error
+	FindWishlists(uId uint) ([]*domain.Wishlist, error)
+	FindWishlistByID(id uint) (*domain.Wishlist, error)
+	FindWishlistByShareToken(token string) (*domain.Wishlist, error)
+	UpdateWishlist(w *domain.Wishlist) error
+	DeleteWishlist(w *domain.Wishlist) error
+
+	AddWishlistItem(item *domain.WishlistItem) error
+	DeleteWishlistItem(id uint) error
+
+	FindChangedWishlistItems() ([]WishlistItemChange, error)
+	MarkWishlistItemSeen(id uint, price money.Money, stock uint) error
+}
+
+// WishlistItemChange is a wishlist item whose product price or stock differs from what its owner last saw
+type WishlistItemChange struct {
+	ItemId        uint
+	UserId        uint
+	Phone         string
+	ProductId     uint
+	ProductName   string
+	Price         money.Money `gorm:"embedded;embeddedPrefix:price_"`
+	Stock         uint
+	LastSeenPrice money.Money `gorm:"embedded;embeddedPrefix:last_seen_price_"`
+	LastSeenStock uint
+}
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+func preloadWishlistItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Items.Product")
+}
+
+func (r wishlistRepository) CreateWishlist(w *domain.Wishlist) error {
+	err := r.db.Create(w).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not create wishlist")
+	}
+	return nil
+}
+
+func (r wishlistRepository) FindWishlists(uId uint) ([]*domain.Wishlist, error) {
+	var wishlists []*domain.Wishlist
+	err := preloadWishlistItems(r.db).Where("user_id = ?", uId).Order("id").Find(&wishlists).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch wishlists")
+	}
+	return wishlists, nil
+}
+
+func (r wishlistRepository) FindWishlistByID(id uint) (*domain.Wishlist, error) {
+	var wishlist domain.Wishlist
+	err := preloadWishlistItems(r.db).First(&wishlist, id).Error
+	if err != nil {
+		return nil, errors.New("wishlist does not exist")
+	}
+	return &wishlist, nil
+}
+
+func (r wishlistRepository) FindWishlistByShareToken(token string) (*domain.Wishlist, error) {
+	var wishlist domain.Wishlist
+	err := preloadWishlistItems(r.db).Where("share_token = ?", token).First(&wishlist).Error
+	if err != nil {
+		return nil, errors.New("wishlist does not exist")
+	}
+	return &wishlist, nil
+}
+
+func (r wishlistRepository) UpdateWishlist(w *domain.Wishlist) error {
+	err := r.db.Omit(clause.Associations).Save(w).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not update wishlist")
+	}
+	return nil
+}
+
+func (r wishlistRepository) DeleteWishlist(w *domain.Wishlist) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("wishlist_id = ?", w.ID).Delete(&domain.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Wishlist{}, w.ID).Error
+	})
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not delete wishlist")
+	}
+	return nil
+}
+
+// AddWishlistItem adds the product to the wishlist, adding it twice keeps the first entry
+func (r wishlistRepository) AddWishlistItem(item *domain.WishlistItem) error {
+	err := r.db.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not add product to wishlist")
+	}
+	return nil
+}
+
+func (r wishlistRepository) DeleteWishlistItem(id uint) error {
+	err := r.db.Delete(&domain.WishlistItem{}, id).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return errors.New("could not remove product from wishlist")
+	}
+	return nil
+}
+
+// FindChangedWishlistItems returns items whose current price or stock differs from the last seen values.
+// Items for a specific variant follow that variant's price and stock.
+func (r wishlistRepository) FindChangedWishlistItems() ([]WishlistItemChange, error) {
+	var changes []WishlistItemChange
+	err := r.db.Raw(`
+		SELECT * FROM (
+			SELECT wi.id AS item_id, w.user_id, u.phone, p.id AS product_id, p.name AS product_name,
+				CASE WHEN COALESCE(v.price_currency, '') <> '' THEN v.price_minor ELSE p.price_minor END AS price_minor,
+				CASE WHEN COALESCE(v.price_currency, '') <> '' THEN v.price_currency ELSE p.price_currency END AS price_currency,
+				CASE WHEN wi.variant_id > 0 THEN COALESCE(v.stock, 0) ELSE p.stock END AS stock,
+				wi.last_seen_price_minor, wi.last_seen_price_currency, wi.last_seen_stock
+			FROM wishlist_items wi
+			JOIN wishlists w ON w.id = wi.wishlist_id
+			JOIN users u ON u.id = w.user_id
+			JOIN products p ON p.id = wi.product_id
+			LEFT JOIN product_variants v ON v.id = wi.variant_id
+		) current
+		WHERE price_minor IS DISTINCT FROM last_seen_price_minor
+			OR price_currency IS DISTINCT FROM last_seen_price_currency
+			OR stock <> last_seen_stock
+		ORDER BY item_id`).Scan(&changes).Error
+	if err != nil {
+		log.Printf("db_err: %v", err)
+		return nil, errors.New("could not fetch wishlist changes")
+	}
+	return changes, nil
+}
+
+func (r wishlistRepository) MarkWishlistItemSeen(id uint, price money.Money, stock uint) error {
+	return r.db.Model(&domain.WishlistItem{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_seen_price_minor":    price.Amount,
+		"last_seen_price_currency": price.Currency,
+		"last_seen_stock":          stock,
+	}).Error
+}
+
+func NewWishlistRepository(db *gorm.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/money"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CartLineOk                = "ok"
+	CartLineRepriced          = "repriced"
+	CartLineRemoved           = "removed"
+	CartLineInsufficientStock = "insufficient_stock"
+)
+
+var (
+	ErrCartChanged = errors.New("some items in your cart changed price or are no longer available, please review your cart")
+
+	ErrCouponNotFound      = errors.New("coupon does not exist")
+	ErrCouponNotRunning    = errors.New("coupon is not active")
+	ErrCouponMinSpend      = errors.New("cart does not reach the coupon minimum spend")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to any item in your cart")
+)
+
+// buyerCurrency is the currency the buyer sees their cart in and pays in
+func (s UserService) buyerCurrency(u domain.User) string {
+	return userCurrency(s.Repo, s.Rates, s.Config, u.ID)
+}
+
+// ApplyCoupon validates the code against the buyer's cart and keeps it on the cart
+func (s UserService) ApplyCoupon(input dto.ApplyCouponRequest, u domain.User) (*dto.CartSummary, error) {
+	code := strings.ToUpper(strings.TrimSpace(input.Code))
+	promotion, err := s.PRepo.FindPromotionByCode(code)
+	if err != nil {
+		return nil, ErrCouponNotFound
+	}
+	items, err := s.Repo.FindCartItems(u.ID)
+	if err != nil {
+		return nil, errors.New("error on finding cart items")
+	}
+
+	summary := s.priceLines(items, s.buyerCurrency(u))
+	if err = s.applyPromotion(summary, promotion, u); err != nil {
+		return nil, err
+	}
+	err = s.PRepo.SaveCartCoupon(&domain.CartCoupon{
+		UserId:      u.ID,
+		PromotionId: promotion.ID,
+		Code:        promotion.Code,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func (s UserService) RemoveCoupon(u domain.User) error {
+	return s.PRepo.DeleteCartCoupon(u.ID)
+}
+
+// GetCartSummary prices the buyer's cart at current prices, with the applied coupon when it still holds
+func (s UserService) GetCartSummary(u domain.User) (*dto.CartSummary, error) {
+	items, err := s.Repo.FindCartItems(u.ID)
+	if err != nil {
+		return nil, errors.New("error on finding cart items")
+	}
+	summary, _, err := s.priceCart(u, items)
+	if err != nil {
+		summary.CouponError = err.Error()
+	}
+	return summary, nil
+}
+
+// AcknowledgeCart accepts the current prices for repriced lines and drops lines whose product is gone
+func (s UserService) AcknowledgeCart(u domain.User) (*dto.CartSummary, error) {
+	items, err := s.Repo.FindCartItems(u.ID)
+	if err != nil {
+		return nil, errors.New("error on finding cart items")
+	}
+	currency := s.buyerCurrency(u)
+	for _, item := range items {
+		line := s.refreshLine(item, currency)
+		switch line.Status {
+		case CartLineRemoved:
+			err = s.Repo.DeleteCartById(item.ID)
+		case CartLineRepriced:
+			item.Price = line.SellerPrice
+			err = s.Repo.UpdateCart(item)
+		}
+		if err != nil {
+			log.Printf("error on refreshing cart item %v", err)
+			return nil, errors.New("error on updating cart")
+		}
+	}
+	return s.GetCartSummary(u)
+}
+
+// priceCart prices the cart with the coupon the buyer applied. When the coupon no longer
+// applies the summary comes back without its discount together with the reason.
+func (s UserService) priceCart(u domain.User, items []domain.Cart) (*dto.CartSummary, *domain.Promotion, error) {
+	summary := s.priceLines(items, s.buyerCurrency(u))
+
+	coupon, err := s.PRepo.FindCartCoupon(u.ID)
+	if err != nil {
+		// no coupon applied
+		return summary, nil, nil
+	}
+	summary.CouponCode = coupon.Code
+
+	promotion, err := s.PRepo.FindPromotionByID(coupon.PromotionId)
+	if err != nil {
+		return summary, nil, ErrCouponNotFound
+	}
+	if err = s.applyPromotion(summary, promotion, u); err != nil {
+		return summary, nil, err
+	}
+	return summary, promotion, nil
+}
+
+// priceLines totals the cart in the currency before any promotion. Every line is priced at the current
+// catalog price and flagged when it changed since it was added, its product was removed or stock ran short.
+// Removed lines are not charged, shipping is charged once per seller.
+func (s UserService) priceLines(items []domain.Cart, currency string) *dto.CartSummary {
+	summary := &dto.CartSummary{Currency: currency, Items: []dto.CartLine{}, Sellers: []dto.CartSellerTotals{}}
+	shipping, err := s.Rates.Convert(s.Config.ShippingFee, currency)
+	if err != nil {
+		log.Printf("error on converting the shipping fee to %s %v", currency, err)
+		shipping = money.Zero(currency)
+	}
+	sellers := map[uint]bool{}
+	for _, item := range items {
+		line := s.refreshLine(item, currency)
+		summary.Items = append(summary.Items, line)
+		switch line.Status {
+		case CartLineRemoved:
+			summary.NeedsAcknowledgement = true
+			continue
+		case CartLineRepriced:
+			summary.NeedsAcknowledgement = true
+		}
+		if !sellers[line.SellerId] {
+			sellers[line.SellerId] = true
+			summary.Sellers = append(summary.Sellers, dto.CartSellerTotals{
+				SellerId:         line.SellerId,
+				Shipping:         shipping,
+				ShippingDiscount: money.Zero(currency),
+			})
+		}
+	}
+	s.computeTotal(summary)
+	return summary
+}
+
+// refreshLine compares the cart snapshot with the catalog, both in the seller's currency,
+// and prices the line in the buyer's currency
+func (s UserService) refreshLine(item domain.Cart, currency string) dto.CartLine {
+	line := dto.CartLine{
+		CartId:      item.ID,
+		ProductId:   item.ProductId,
+		VariantId:   item.VariantId,
+		SellerId:    item.SellerId,
+		Name:        item.Name,
+		ImageUrl:    item.ImageUrl,
+		Qty:         item.Qty,
+		SellerPrice: item.Price,
+		LineTotal:   money.Zero(currency),
+		Discount:    money.Zero(currency),
+		Status:      CartLineOk,
+	}
+	s.checkLine(&line, item)
+
+	price, err := s.Rates.Convert(line.SellerPrice, currency)
+	if err != nil {
+		log.Printf("error on pricing cart item %d in %s %v", item.ID, currency, err)
+		line.Price = money.Zero(currency)
+		line.Status = CartLineRemoved
+		return line
+	}
+	line.Price = price
+	if line.Status == CartLineRepriced {
+		if previous, err := s.Rates.Convert(item.Price, currency); err == nil {
+			line.PreviousPrice = &previous
+		}
+	}
+	if line.Status != CartLineRemoved {
+		line.LineTotal = line.Price.Mul(int64(line.Qty))
+	}
+	return line
+}
+
+// checkLine flags the line when its product is gone, its price changed or stock ran short
+func (s UserService) checkLine(line *dto.CartLine, item domain.Cart) {
+	product, err := s.CRepo.FindProductByID(int(item.ProductId))
+	if err != nil {
+		line.Status = CartLineRemoved
+		return
+	}
+	var variant *domain.ProductVariant
+	for i := range product.Variants {
+		if product.Variants[i].ID == item.VariantId {
+			variant = &product.Variants[i]
+		}
+	}
+	if variant == nil {
+		line.Status = CartLineRemoved
+		return
+	}
+
+	line.Available = variant.Stock
+	if price := variant.PriceFor(product); price != item.Price {
+		line.SellerPrice = price
+		line.Status = CartLineRepriced
+	} else if variant.Stock < item.Qty {
+		line.Status = CartLineInsufficientStock
+	}
+}
+
+// computeTotal adds up the lines of every seller, taxes each seller's discounted subtotal
+// and totals the cart from the sellers' totals
+func (s UserService) computeTotal(summary *dto.CartSummary) {
+	zero := money.Zero(summary.Currency)
+	index := map[uint]int{}
+	for i := range summary.Sellers {
+		index[summary.Sellers[i].SellerId] = i
+		summary.Sellers[i].Subtotal = zero
+		summary.Sellers[i].Discount = zero
+	}
+	for _, line := range summary.Items {
+		if line.Status == CartLineRemoved {
+			continue
+		}
+		seller := &summary.Sellers[index[line.SellerId]]
+		seller.Subtotal = seller.Subtotal.Add(line.LineTotal)
+		seller.Discount = seller.Discount.Add(line.Discount)
+	}
+
+	summary.Subtotal, summary.Discount, summary.Shipping, summary.ShippingDiscount, summary.Tax = zero, zero, zero, zero, zero
+	for i := range summary.Sellers {
+		seller := &summary.Sellers[i]
+		seller.Tax = seller.Subtotal.Sub(seller.Discount).Percent(s.Config.TaxRate)
+		seller.Total = seller.Subtotal.Sub(seller.Discount).Add(seller.Shipping).Sub(seller.ShippingDiscount).Add(seller.Tax)
+
+		summary.Subtotal = summary.Subtotal.Add(seller.Subtotal)
+		summary.Discount = summary.Discount.Add(seller.Discount)
+		summary.Shipping = summary.Shipping.Add(seller.Shipping)
+		summary.ShippingDiscount = summary.ShippingDiscount.Add(seller.ShippingDiscount)
+		summary.Tax = summary.Tax.Add(seller.Tax)
+	}
+	summary.Total = summary.Subtotal.Sub(summary.Discount).Add(summary.Shipping).Sub(summary.ShippingDiscount).Add(summary.Tax)
+}
+
+// applyPromotion checks that the promotion can be used by the buyer on this cart
+// and spreads its discount over the lines it applies to.
+func (s UserService) applyPromotion(summary *dto.CartSummary, p *domain.Promotion, u domain.User) error {
+	if !p.IsRunning(time.Now()) {
+		return ErrCouponNotRunning
+	}
+	if p.UsageLimit > 0 && p.UsedCount >= p.UsageLimit {
+		return repository.ErrPromotionUsedUp
+	}
+	if p.PerUserLimit > 0 {
+		used, err := s.PRepo.CountRedemptions(p.ID, u.ID)
+		if err != nil {
+			return err
+		}
+		if used >= int64(p.PerUserLimit) {
+			return repository.ErrPromotionUsedUp
+		}
+	}
+
+	eligible, err := s.eligibleLines(summary, p)
+	if err != nil {
+		return err
+	}
+	if len(eligible) == 0 {
+		return ErrCouponNotApplicable
+	}
+	eligibleTotal := money.Zero(summary.Currency)
+	for _, i := range eligible {
+		eligibleTotal = eligibleTotal.Add(summary.Items[i].LineTotal)
+	}
+	// amounts of the promotion are in the currency it was created in
+	if p.MinSpend.IsSet() {
+		minSpend, err := s.Rates.Convert(p.MinSpend, summary.Currency)
+		if err != nil {
+			return errors.Wrap(ErrCouponNotApplicable, err.Error())
+		}
+		if eligibleTotal.Less(minSpend) {
+			return errors.Wrapf(ErrCouponMinSpend, "spend at least %s", minSpend)
+		}
+	}
+
+	switch p.Type {
+	case domain.PromotionPercentage:
+		for _, i := range eligible {
+			summary.Items[i].Discount = summary.Items[i].LineTotal.Percent(p.Value)
+		}
+	case domain.PromotionFixed:
+		amount, err := s.Rates.Convert(p.Amount, summary.Currency)
+		if err != nil {
+			return errors.Wrap(ErrCouponNotApplicable, err.Error())
+		}
+		spreadDiscount(summary, eligible, money.Min(amount, eligibleTotal), eligibleTotal)
+	case domain.PromotionBuyXGetY:
+		freeCheapestUnits(summary, eligible, p.BuyQty, p.GetQty)
+	case domain.PromotionFreeShipping:
+		sellers := map[uint]bool{}
+		for _, i := range eligible {
+			sellers[summary.Items[i].SellerId] = true
+		}
+		for i := range summary.Sellers {
+			if sellers[summary.Sellers[i].SellerId] {
+				summary.Sellers[i].ShippingDiscount = summary.Sellers[i].Shipping
+			}
+		}
+	}
+
+	summary.CouponCode = p.Code
+	s.computeTotal(summary)
+	return nil
+}
+
+// eligibleLines returns the index of every cart line inside the promotion's seller and category scope
+func (s UserService) eligibleLines(summary *dto.CartSummary, p *domain.Promotion) ([]int, error) {
+	var categories map[uint]bool
+	if p.CategoryId > 0 {
+		ids, err := s.CRepo.FindCategoryDescendantIds(p.CategoryId)
+		if err != nil {
+			return nil, err
+		}
+		categories = map[uint]bool{}
+		for _, id := range ids {
+			categories[id] = true
+		}
+	}
+
+	var eligible []int
+	for i, line := range summary.Items {
+		if line.Status == CartLineRemoved {
+			continue
+		}
+		if p.SellerId > 0 && line.SellerId != p.SellerId {
+			continue
+		}
+		if categories != nil {
+			product, err := s.CRepo.FindProductByID(int(line.ProductId))
+			if err != nil || !categories[product.CategoryId] {
+				continue
+			}
+		}
+		eligible = append(eligible, i)
+	}
+	return eligible, nil
+}
+
+// spreadDiscount splits a fixed amount over the lines in proportion to their totals,
+// the last line takes the rounding remainder so the parts add up to the amount.
+func spreadDiscount(summary *dto.CartSummary, lines []int, amount money.Money, total money.Money) {
+	remaining := amount
+	for n, i := range lines {
+		share := amount.Share(summary.Items[i].LineTotal.Amount, total.Amount)
+		if n == len(lines)-1 || remaining.Less(share) {
+			share = remaining
+		}
+		summary.Items[i].Discount = share
+		remaining = remaining.Sub(share)
+	}
+}
+
+// freeCheapestUnits gives away getQty units for every buyQty + getQty units bought,
+// always the cheapest ones.
+func freeCheapestUnits(summary *dto.CartSummary, lines []int, buyQty uint, getQty uint) {
+	total := uint(0)
+	for _, i := range lines {
+		total += summary.Items[i].Qty
+	}
+	free := total / (buyQty + getQty) * getQty
+
+	cheapest := append([]int(nil), lines...)
+	sort.SliceStable(cheapest, func(a, b int) bool {
+		return summary.Items[cheapest[a]].Price.Less(summary.Items[cheapest[b]].Price)
+	})
+	for _, i := range cheapest {
+		if free == 0 {
+			break
+		}
+		line := &summary.Items[i]
+		qty := min(line.Qty, free)
+		line.Discount = line.Discount.Add(line.Price.Mul(int64(qty)))
+		free -= qty
+	}
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/helper"
+	"ecommerce-app/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+)
+
+// guest cart tokens are 16 random bytes, hex encoded
+var cartTokenPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// cartStore is the cart of a logged in user or of a guest, so both support the same operations
+type cartStore interface {
+	Items() ([]domain.Cart, error)
+	Item(variantId uint) (domain.Cart, error)
+	Create(c domain.Cart) error
+	Update(c domain.Cart) error
+	Delete(id uint) error
+	Clear() error
+}
+
+type userCart struct {
+	repo   repository.UserRepository
+	userId uint
+}
+
+func (c userCart) Items() ([]domain.Cart, error)      { return c.repo.FindCartItems(c.userId) }
+func (c userCart) Item(vId uint) (domain.Cart, error) { return c.repo.FindCartItem(c.userId, vId) }
+func (c userCart) Update(item domain.Cart) error      { return c.repo.UpdateCart(item) }
+func (c userCart) Delete(id uint) error               { return c.repo.DeleteCartById(id) }
+func (c userCart) Clear() error                       { return c.repo.DeleteCartItems(c.userId) }
+func (c userCart) Create(item domain.Cart) error {
+	item.UserId = c.userId
+	return c.repo.CreateCart(item)
+}
+
+type guestCart struct {
+	repo  repository.UserRepository
+	token string
+}
+
+func (c guestCart) Items() ([]domain.Cart, error)      { return c.repo.FindGuestCartItems(c.token) }
+func (c guestCart) Item(vId uint) (domain.Cart, error) { return c.repo.FindGuestCartItem(c.token, vId) }
+func (c guestCart) Create(item domain.Cart) error      { return c.repo.CreateGuestCart(c.token, item) }
+func (c guestCart) Update(item domain.Cart) error      { return c.repo.UpdateGuestCart(c.token, item) }
+func (c guestCart) Delete(id uint) error               { return c.repo.DeleteGuestCartById(c.token, id) }
+func (c guestCart) Clear() error                       { return c.repo.DeleteGuestCartItems(c.token) }
+
+func (s UserService) userCart(userId uint) cartStore {
+	return userCart{repo: s.Repo, userId: userId}
+}
+
+func (s UserService) guestCart(token string) cartStore {
+	return guestCart{repo: s.Repo, token: token}
+}
+
+// ValidCartToken reports whether the token looks like one handed out by NewCartToken
+func ValidCartToken(token string) bool {
+	return cartTokenPattern.MatchString(token)
+}
+
+func NewCartToken() (string, error) {
+	return helper.RandomToken(16)
+}
+
+// AddToGuestCart works like CreateCart for a visitor identified by their cart token
+func (s UserService) AddToGuestCart(input dto.CreateCartRequest, token string) ([]domain.Cart, error) {
+	if !ValidCartToken(token) {
+		return nil, errors.New("cart token is not valid")
+	}
+	return s.addCartItem(s.guestCart(token), input, 0)
+}
+
+func (s UserService) UpdateGuestCartItem(productId uint, input dto.UpdateCartRequest, token string) ([]domain.Cart, error) {
+	if !ValidCartToken(token) {
+		return nil, ErrCartItemNotFound
+	}
+	return s.updateCartItem(s.guestCart(token), productId, input, 0)
+}
+
+func (s UserService) RemoveGuestCartItem(productId uint, variantId uint, token string) ([]domain.Cart, error) {
+	if !ValidCartToken(token) {
+		return nil, ErrCartItemNotFound
+	}
+	return removeCartItem(s.guestCart(token), productId, variantId)
+}
+
+func (s UserService) ClearGuestCart(token string) error {
+	if !ValidCartToken(token) {
+		return nil
+	}
+	return s.guestCart(token).Clear()
+}
+
+// GetGuestCartSummary prices the guest cart in the currency the visitor asked for, the default one when
+// it is not supported. Coupons need an account so none is applied.
+func (s UserService) GetGuestCartSummary(token string, currency string) (*dto.CartSummary, error) {
+	currency, err := supportedCurrency(s.Rates, currency)
+	if err != nil {
+		currency = s.Config.Currency
+	}
+	if !ValidCartToken(token) {
+		return s.priceLines(nil, currency), nil
+	}
+	items, err := s.guestCart(token).Items()
+	if err != nil {
+		return nil, errors.New("error on finding cart items")
+	}
+	return s.priceLines(items, currency), nil
+}
+
+// mergeGuestCart moves the guest cart into the user's cart after they register or log in.
+// When both carts hold the same variant the quantities are added up. Every line is held to the rules of
+// addCartItem: lines of the user's own products or of products gone since are dropped, and quantities are
+// capped to the stock and the max per order. A failed merge leaves the guest cart in place and does not
+// fail the login.
+func (s UserService) mergeGuestCart(token string, userId uint) {
+	if userId == 0 || !ValidCartToken(token) {
+		return
+	}
+	items, err := s.guestCart(token).Items()
+	if err != nil {
+		log.Printf("error on finding guest cart items %v", err)
+		return
+	}
+	store := s.userCart(userId)
+	var lines []domain.Cart
+	for _, item := range items {
+		product, variant, err := s.cartProduct(item.ProductId, item.VariantId, userId)
+		if err != nil {
+			continue
+		}
+		line, _ := store.Item(variant.ID)
+		qty := capCartQty(product, variant, line.Qty+item.Qty)
+		if qty <= line.Qty {
+			continue
+		}
+		if line.ID == 0 {
+			line = item
+			line.ID = 0
+			line.VariantId = variant.ID
+		}
+		line.Qty = qty
+		lines = append(lines, line)
+	}
+	if err = s.Repo.MergeGuestCart(token, userId, lines); err != nil {
+		log.Printf("error on merging guest cart %v", err)
+	}
+}
+
+var (
+	ErrVariantRequired  = errors.New("please choose a variant of the product")
+	ErrInvalidQty       = errors.New("quantity must be at least 1")
+	ErrQtyExceedsStock  = errors.New("not enough stock for the requested quantity")
+	ErrQtyExceedsLimit  = errors.New("quantity is above the maximum allowed per order")
+	ErrOwnProduct       = errors.New("you cannot buy your own product")
+	ErrCartItemNotFound = errors.New("product is not in the cart")
+)
+
+// cartProduct loads the product and variant a buyer wants to put in their cart.
+// buyerId is 0 for guests.
+func (s UserService) cartProduct(productId uint, variantId uint, buyerId uint) (*domain.Product, *domain.ProductVariant, error) {
+	if productId == 0 {
+		return nil, nil, ErrProductNotFound
+	}
+	product, err := s.CRepo.FindProductByID(int(productId))
+	if err != nil {
+		return nil, nil, productLookupError(err)
+	}
+	if buyerId > 0 && product.UserId == buyerId {
+		return nil, nil, ErrOwnProduct
+	}
+	variant, err := selectVariant(product, variantId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return product, variant, nil
+}
+
+// checkCartQty caps the quantity of a line to the variant's stock and the product's max per order
+func checkCartQty(product *domain.Product, variant *domain.ProductVariant, qty uint) error {
+	if qty < 1 {
+		return ErrInvalidQty
+	}
+	if product.MaxPerOrder > 0 && qty > product.MaxPerOrder {
+		return fmt.Errorf("%w: at most %d", ErrQtyExceedsLimit, product.MaxPerOrder)
+	}
+	if qty > variant.Stock {
+		return fmt.Errorf("%w: %d available", ErrQtyExceedsStock, variant.Stock)
+	}
+	return nil
+}
+
+// capCartQty lowers the quantity to what checkCartQty accepts
+func capCartQty(product *domain.Product, variant *domain.ProductVariant, qty uint) uint {
+	if product.MaxPerOrder > 0 && qty > product.MaxPerOrder {
+		qty = product.MaxPerOrder
+	}
+	return min(qty, variant.Stock)
+}
+
+func (s UserService) addCartItem(store cartStore, input dto.CreateCartRequest, buyerId uint) ([]domain.Cart, error) {
+	if input.Qty < 1 {
+		return nil, ErrInvalidQty
+	}
+	product, variant, err := s.cartProduct(input.ProductId, input.VariantId, buyerId)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, _ := store.Item(variant.ID)
+	qty := input.Qty + cart.Qty
+	if err = checkCartQty(product, variant, qty); err != nil {
+		return nil, err
+	}
+
+	if cart.ID > 0 {
+		cart.Qty = qty
+		err = store.Update(cart)
+		if err != nil {
+			log.Printf("Error on updating cart item %v", err)
+			return nil, errors.New("error on updating cart item")
+		}
+	} else {
+		err = store.Create(domain.Cart{
+			ProductId: product.ID,
+			VariantId: variant.ID,
+			Name:      variantName(product, variant),
+			ImageUrl:  variant.ImageFor(product),
+			Qty:       qty,
+			Price:     variant.PriceFor(product),
+			SellerId:  product.UserId,
+		})
+		if err != nil {
+			log.Printf("Error on creating cart item %v", err)
+			return nil, errors.New("error on creating cart item")
+		}
+	}
+	return store.Items()
+}
+
+func (s UserService) updateCartItem(store cartStore, productId uint, input dto.UpdateCartRequest, buyerId uint) ([]domain.Cart, error) {
+	if input.Qty < 1 {
+		return nil, ErrInvalidQty
+	}
+	lines, err := findCartLines(store, productId, input.VariantId)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) > 1 {
+		return nil, ErrVariantRequired
+	}
+	cart := lines[0]
+
+	product, variant, err := s.cartProduct(productId, cart.VariantId, buyerId)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkCartQty(product, variant, input.Qty); err != nil {
+		return nil, err
+	}
+
+	cart.Qty = input.Qty
+	err = store.Update(cart)
+	if err != nil {
+		log.Printf("Error on updating cart item %v", err)
+		return nil, errors.New("error on updating cart item")
+	}
+	return store.Items()
+}
+
+func removeCartItem(store cartStore, productId uint, variantId uint) ([]domain.Cart, error) {
+	lines, err := findCartLines(store, productId, variantId)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if err = store.Delete(line.ID); err != nil {
+			log.Printf("Error on deleting cart item %v", err)
+			return nil, errors.New("error on deleting cart item")
+		}
+	}
+	return store.Items()
+}
+
+// findCartLines returns the cart lines of the product, only the one of the given variant when variantId is set
+func findCartLines(store cartStore, productId uint, variantId uint) ([]domain.Cart, error) {
+	items, err := store.Items()
+	if err != nil {
+		return nil, errors.New("error on finding cart items")
+	}
+	var lines []domain.Cart
+	for _, item := range items {
+		if item.ProductId == productId && (variantId == 0 || item.VariantId == variantId) {
+			lines = append(lines, item)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, ErrCartItemNotFound
+	}
+	return lines, nil
+}
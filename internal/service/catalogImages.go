@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bytes"
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/helper"
+	"fmt"
+	"image"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MaxImageSize  = 5 * 1024 * 1024
+	MaxImageSide  = 4096 // pixels, decoding holds the whole image in memory
+	thumbnailSize = 400
+)
+
+// imageTypes maps the accepted image content types to their file extension
+var imageTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+var (
+	ErrImageTooLarge    = fmt.Errorf("image must be at most %d MB", MaxImageSize/1024/1024)
+	ErrImageDimensions  = fmt.Errorf("image must be at most %dx%d pixels", MaxImageSide, MaxImageSide)
+	ErrImageType        = errors.New("image must be a jpeg, png or gif file")
+	ErrImageNotFound    = errors.New("image does not exist")
+	ErrInvalidImageList = errors.New("image order must list every image of the product exactly once")
+)
+
+// storedImage is an uploaded image and its thumbnail after they have been written to storage
+type storedImage struct {
+	key, thumbnailKey string
+	contentType       string
+	size              int64
+	width, height     int
+}
+
+// UploadProductImage validates the image, stores it with a thumbnail and appends it to the product images
+func (s CatalogService) UploadProductImage(productId int, data []byte, user domain.User) (*domain.ProductImage, error) {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.storeImage(fmt.Sprintf("products/%d", product.ID), data)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &domain.ProductImage{
+		ProductId:    product.ID,
+		Key:          stored.key,
+		ThumbnailKey: stored.thumbnailKey,
+		Url:          s.mediaUrl(stored.key),
+		ThumbnailUrl: s.mediaUrl(stored.thumbnailKey),
+		ContentType:  stored.contentType,
+		Size:         stored.size,
+		Width:        stored.width,
+		Height:       stored.height,
+	}
+	err = s.Repo.CreateProductImage(img)
+	if err != nil {
+		s.removeFiles(stored.key, stored.thumbnailKey)
+		return nil, err
+	}
+	return img, nil
+}
+
+// ReorderProductImages sets the image order, ids must contain every image of the product
+func (s CatalogService) ReorderProductImages(productId int, ids []uint, user domain.User) ([]*domain.ProductImage, error) {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) != len(product.Images) {
+		return nil, ErrInvalidImageList
+	}
+	owned := map[uint]bool{}
+	for _, img := range product.Images {
+		owned[img.ID] = true
+	}
+	for _, id := range ids {
+		if !owned[id] {
+			return nil, ErrInvalidImageList
+		}
+		delete(owned, id)
+	}
+
+	err = s.Repo.ReorderProductImages(product.ID, ids)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.FindProductImages(product.ID)
+}
+
+func (s CatalogService) DeleteProductImage(productId int, imageId int, user domain.User) error {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return err
+	}
+	for _, img := range product.Images {
+		if img.ID != uint(imageId) {
+			continue
+		}
+		err = s.Repo.DeleteProductImage(&img)
+		if err != nil {
+			return err
+		}
+		s.removeFiles(img.Key, img.ThumbnailKey)
+		return nil
+	}
+	return ErrImageNotFound
+}
+
+// UploadCategoryImage stores the image and makes it the category image
+func (s CatalogService) UploadCategoryImage(id int, data []byte) (*domain.Category, error) {
+	category, err := s.Repo.FindCategoryByID(id)
+	if err != nil {
+		return nil, ErrCategoryNotFound
+	}
+	stored, err := s.storeImage(fmt.Sprintf("categories/%d", category.ID), data)
+	if err != nil {
+		return nil, err
+	}
+	// categories only show a small image, so the thumbnail is all that is kept
+	s.removeFiles(stored.key)
+	category.ImageUrl = s.mediaUrl(stored.thumbnailKey)
+	return s.Repo.EditCategory(category)
+}
+
+// storeImage checks the size, pixel dimensions and real content type of the upload and writes the image
+// and its thumbnail below prefix. The dimensions are read from the header before the image is decoded.
+func (s CatalogService) storeImage(prefix string, data []byte) (*storedImage, error) {
+	if len(data) > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return nil, ErrImageType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageType
+	}
+	if config.Width > MaxImageSide || config.Height > MaxImageSide {
+		return nil, ErrImageDimensions
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageType
+	}
+
+	thumb, thumbType, err := helper.EncodeImage(helper.Thumbnail(src, thumbnailSize), format)
+	if err != nil {
+		return nil, errors.New("could not create thumbnail")
+	}
+
+	name, err := helper.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	stored := &storedImage{
+		key:          fmt.Sprintf("%s/%s.%s", prefix, name, ext),
+		thumbnailKey: fmt.Sprintf("%s/%s_thumb.%s", prefix, name, imageTypes[thumbType]),
+		contentType:  contentType,
+		size:         int64(len(data)),
+		width:        src.Bounds().Dx(),
+		height:       src.Bounds().Dy(),
+	}
+
+	if err = s.Storage.Put(stored.key, data, contentType); err != nil {
+		log.Printf("storage_err: %v", err)
+		return nil, errors.New("could not store image")
+	}
+	if err = s.Storage.Put(stored.thumbnailKey, thumb, thumbType); err != nil {
+		log.Printf("storage_err: %v", err)
+		s.removeFiles(stored.key)
+		return nil, errors.New("could not store image")
+	}
+	return stored, nil
+}
+
+// removeFiles deletes stored files on a best effort basis, a leftover file is only wasted space
+func (s CatalogService) removeFiles(keys ...string) {
+	for _, key := range keys {
+		if err := s.Storage.Delete(key); err != nil {
+			log.Printf("storage_err: %v", err)
+		}
+	}
+}
+
+func (s CatalogService) mediaUrl(key string) string {
+	base := strings.TrimRight(s.Config.MediaBaseUrl, "/")
+	if len(base) == 0 {
+		base = "/media"
+	}
+	return base + "/" + key
+}
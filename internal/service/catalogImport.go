@@ -0,0 +1,224 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/pkg/money"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxImportRows keeps a single import within one reasonably sized transaction
+const maxImportRows = 5000
+
+// ProductCsvHeader is the column layout shared by product import and export
+var ProductCsvHeader = []string{"external_sku", "name", "description", "category", "price", "stock", "image_url"}
+
+var ErrInvalidCsv = errors.New("csv file is not valid")
+
+// ImportProducts validates every CSV row and saves the valid ones in a single transaction.
+// Rows with an external_sku already used by the seller update that product, other rows create new ones.
+func (s CatalogService) ImportProducts(r io.Reader, user domain.User) (*dto.ProductImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(ProductCsvHeader)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrInvalidCsv
+	}
+	for i, col := range header {
+		if strings.TrimSpace(strings.ToLower(col)) != ProductCsvHeader[i] {
+			return nil, errors.Wrapf(ErrInvalidCsv, "expected columns %s", strings.Join(ProductCsvHeader, ","))
+		}
+	}
+
+	existing, err := s.Repo.FindSellerProducts(int(user.ID))
+	if err != nil {
+		return nil, err
+	}
+	bySku := map[string]*domain.Product{}
+	for _, p := range existing {
+		if len(p.ExternalSku) > 0 {
+			bySku[p.ExternalSku] = p
+		}
+	}
+	categories, err := s.categoryLookup()
+	if err != nil {
+		return nil, err
+	}
+	currency := s.sellerCurrency(user)
+
+	result := &dto.ProductImportResult{Errors: []dto.ProductImportRowError{}}
+	var valid []*domain.Product
+	seen := map[string]int{}
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if row-1 > maxImportRows {
+			return nil, errors.Wrapf(ErrInvalidCsv, "at most %d rows can be imported at once", maxImportRows)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, dto.ProductImportRowError{Row: row, Message: err.Error()})
+			continue
+		}
+
+		product, err := parseProductRow(record, categories, currency)
+		if err == nil && len(product.ExternalSku) > 0 {
+			if first, ok := seen[product.ExternalSku]; ok {
+				err = fmt.Errorf("external_sku is already used on row %d", first)
+			}
+			seen[product.ExternalSku] = row
+		}
+		if err == nil {
+			product, err = mergeImportedProduct(product, bySku[product.ExternalSku], user)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, dto.ProductImportRowError{Row: row, Message: err.Error()})
+			continue
+		}
+
+		s.setSortPrice(product)
+		if product.ID > 0 {
+			result.Updated++
+		} else {
+			result.Created++
+		}
+		valid = append(valid, product)
+	}
+
+	if len(valid) > 0 {
+		err = s.Repo.SaveProducts(valid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ExportProducts writes the seller's catalogue as CSV in the import format
+func (s CatalogService) ExportProducts(w io.Writer, user domain.User) error {
+	products, err := s.Repo.FindSellerProducts(int(user.ID))
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err = writer.Write(ProductCsvHeader); err != nil {
+		return err
+	}
+	for _, p := range products {
+		err = writer.Write([]string{
+			p.ExternalSku,
+			p.Name,
+			p.Description,
+			strconv.FormatUint(uint64(p.CategoryId), 10),
+			p.Price.Decimal(),
+			strconv.FormatUint(uint64(p.Stock), 10),
+			p.ImageUrl,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// categoryLookup resolves the category column, which holds either a category id or a unique category name
+func (s CatalogService) categoryLookup() (func(string) (uint, error), error) {
+	categories, err := s.Repo.FindCategories()
+	if err != nil {
+		return nil, err
+	}
+	ids := map[uint]bool{}
+	names := map[string][]uint{}
+	for _, c := range categories {
+		ids[c.ID] = true
+		key := strings.ToLower(c.Name)
+		names[key] = append(names[key], c.ID)
+	}
+
+	return func(value string) (uint, error) {
+		if len(value) == 0 {
+			return 0, errors.New("category is required")
+		}
+		if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+			if !ids[uint(id)] {
+				return 0, fmt.Errorf("category %d does not exist", id)
+			}
+			return uint(id), nil
+		}
+		matches := names[strings.ToLower(value)]
+		switch len(matches) {
+		case 0:
+			return 0, fmt.Errorf("category %q does not exist", value)
+		case 1:
+			return matches[0], nil
+		default:
+			return 0, fmt.Errorf("category name %q is ambiguous, use the category id", value)
+		}
+	}, nil
+}
+
+// parseProductRow reads a CSV row, prices are decimal amounts in the seller's currency
+func parseProductRow(record []string, category func(string) (uint, error), currency string) (*domain.Product, error) {
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+	product := &domain.Product{
+		ExternalSku: record[0],
+		Name:        record[1],
+		Description: record[2],
+		ImageUrl:    record[6],
+	}
+	if len(product.Name) == 0 {
+		return nil, errors.New("name is required")
+	}
+
+	categoryId, err := category(record[3])
+	if err != nil {
+		return nil, err
+	}
+	product.CategoryId = categoryId
+
+	price, err := money.Parse(record[4], currency)
+	if err != nil || price.IsNegative() {
+		return nil, fmt.Errorf("price must be a non negative %s amount", currency)
+	}
+	product.Price = price
+
+	stock, err := strconv.ParseUint(record[5], 10, 32)
+	if err != nil {
+		return nil, errors.New("stock must be a non negative whole number")
+	}
+	product.Stock = uint(stock)
+
+	return product, nil
+}
+
+// mergeImportedProduct applies an imported row on top of the existing product with the same external sku
+func mergeImportedProduct(row *domain.Product, existing *domain.Product, user domain.User) (*domain.Product, error) {
+	if existing == nil {
+		row.UserId = user.ID
+		return row, nil
+	}
+	if existing.HasOptions() && existing.Stock != row.Stock {
+		return nil, ErrStockManagedByVariants
+	}
+	existing.Name = row.Name
+	existing.Description = row.Description
+	existing.CategoryId = row.CategoryId
+	existing.Price = row.Price
+	existing.Stock = row.Stock
+	existing.ImageUrl = row.ImageUrl
+	return existing, nil
+}
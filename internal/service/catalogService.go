@@ -6,19 +6,56 @@ import (
 	"ecommerce-app/internal/dto"
 	"ecommerce-app/internal/helper"
 	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/money"
+	"ecommerce-app/pkg/storage"
+	"log"
 
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProductNotFound = errors.New("product does not exist")
+	ErrProductNotOwned = errors.New("you are not allowed to manage this product")
+	ErrInvalidProduct  = errors.New("product name and category are required and price and stock cannot be negative")
+	ErrInvalidStock    = errors.New("stock cannot be negative")
+	ErrInvalidQuery    = errors.New("invalid product listing parameters")
+	ErrPriceCurrency   = errors.New("prices must be in the seller's currency")
+	ErrUnknownCategory = errors.New("product category does not exist")
+
+	ErrDuplicateExternalSku = errors.New("external sku is already used by another of your products")
+
+	ErrCategoryNotFound = errors.New("category does not exist")
+	ErrParentNotFound   = errors.New("parent category does not exist")
+	ErrCategoryCycle    = errors.New("a category cannot be moved under itself or one of its sub categories")
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
 )
 
 type CatalogService struct {
-	Repo   repository.CatalogRepository
-	Auth   helper.Auth
-	Config config.AppConfig
+	Repo    repository.CatalogRepository
+	URepo   repository.UserRepository
+	Auth    helper.Auth
+	Config  config.AppConfig
+	Storage storage.Storage
+	Rates   *money.Rates
 }
 
 func (s CatalogService) CreateCategory(input dto.CreateCategoryRequest) error {
+	var parentId uint
+	if input.ParentId != nil && *input.ParentId > 0 {
+		parentId = *input.ParentId
+		_, err := s.Repo.FindCategoryByID(int(parentId))
+		if err != nil {
+			return ErrParentNotFound
+		}
+	}
 	err := s.Repo.CreateCategory(&domain.Category{
 		Name:         input.Name,
+		ParentId:     parentId,
 		ImageUrl:     input.ImageUrl,
 		DisplayOrder: input.DisplayOrder,
 	})
@@ -29,7 +66,7 @@ func (s CatalogService) EditCategory(id int, input dto.CreateCategoryRequest) (*
 
 	existCat, err := s.Repo.FindCategoryByID(id)
 	if err != nil {
-		return nil, errors.New("category does not exist")
+		return nil, ErrCategoryNotFound
 	}
 	if len(input.Name) > 0 {
 		existCat.Name = input.Name
@@ -37,8 +74,15 @@ func (s CatalogService) EditCategory(id int, input dto.CreateCategoryRequest) (*
 	if len(input.ImageUrl) > 0 {
 		existCat.ImageUrl = input.ImageUrl
 	}
-	if input.ParentId > 0 {
-		existCat.ParentId = input.ParentId
+	// a parent id of 0 moves the category to the root, leaving it out keeps the current parent
+	if input.ParentId != nil && *input.ParentId != existCat.ParentId {
+		if *input.ParentId > 0 {
+			err = s.validateParent(existCat.ID, *input.ParentId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		existCat.ParentId = *input.ParentId
 	}
 	if input.DisplayOrder > 0 {
 		existCat.DisplayOrder = input.DisplayOrder
@@ -48,14 +92,32 @@ func (s CatalogService) EditCategory(id int, input dto.CreateCategoryRequest) (*
 	return updatedCat, err
 }
 
-func (s CatalogService) DeleteCategory(id int) error {
-	err := s.Repo.DeleteCategory(id)
+// validateParent makes sure the new parent exists and is not the category itself or one of its descendants
+func (s CatalogService) validateParent(id uint, parentId uint) error {
+	_, err := s.Repo.FindCategoryByID(int(parentId))
+	if err != nil {
+		return ErrParentNotFound
+	}
+	descendants, err := s.Repo.FindCategoryDescendantIds(id)
 	if err != nil {
-		return errors.New("category does not exist to delete")
+		return err
+	}
+	for _, d := range descendants {
+		if d == parentId {
+			return ErrCategoryCycle
+		}
 	}
 	return nil
 }
 
+func (s CatalogService) DeleteCategory(id int) error {
+	err := s.Repo.DeleteCategory(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCategoryNotFound
+	}
+	return err
+}
+
 func (s CatalogService) GetCategories() ([]*domain.Category, error) {
 	categories, err := s.Repo.FindCategories()
 	if err != nil {
@@ -67,24 +129,88 @@ func (s CatalogService) GetCategories() ([]*domain.Category, error) {
 func (s CatalogService) GetCategory(id int) (*domain.Category, error) {
 	category, err := s.Repo.FindCategoryByID(id)
 	if err != nil {
-		return nil, errors.New("category does not exist")
+		return nil, ErrCategoryNotFound
 	}
 	return category, nil
 }
 
+// GetCategoryTree returns the root categories with their sub categories nested, each level ordered by display order
+func (s CatalogService) GetCategoryTree() ([]*dto.CategoryNode, error) {
+	categories, err := s.Repo.FindCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*dto.CategoryNode, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &dto.CategoryNode{
+			ID:           c.ID,
+			Name:         c.Name,
+			ParentId:     c.ParentId,
+			ImageUrl:     c.ImageUrl,
+			DisplayOrder: c.DisplayOrder,
+			Children:     []*dto.CategoryNode{},
+		}
+	}
+
+	// categories come back sorted, so appending keeps every level in display order
+	roots := []*dto.CategoryNode{}
+	for _, c := range categories {
+		node := nodes[c.ID]
+		parent, ok := nodes[c.ParentId]
+		if c.ParentId == 0 || !ok {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	return roots, nil
+}
+
+// GetCategoryBreadcrumbs returns the path from the root category to the given category
+func (s CatalogService) GetCategoryBreadcrumbs(id int) ([]dto.Breadcrumb, error) {
+	path, err := s.Repo.FindCategoryAncestors(uint(id))
+	if err != nil {
+		return nil, ErrCategoryNotFound
+	}
+	crumbs := make([]dto.Breadcrumb, 0, len(path))
+	for _, c := range path {
+		crumbs = append(crumbs, dto.Breadcrumb{ID: c.ID, Name: c.Name})
+	}
+	return crumbs, nil
+}
+
 // Products
 
 func (s CatalogService) CreateProduct(input dto.CreateProductRequest, user domain.User) error {
-	err := s.Repo.CreateProduct(&domain.Product{
+	if !validProductInput(input) {
+		return ErrInvalidProduct
+	}
+	err := s.checkCategory(input.CategoryId)
+	if err != nil {
+		return err
+	}
+	err = s.checkExternalSku(user.ID, 0, input.ExternalSku)
+	if err != nil {
+		return err
+	}
+	price, err := s.sellerPrice(input.Price, user)
+	if err != nil {
+		return err
+	}
+	product := &domain.Product{
 		Name:        input.Name,
+		ExternalSku: input.ExternalSku,
 		ImageUrl:    input.ImageUrl,
 		Description: input.Description,
 		CategoryId:  input.CategoryId,
-		Price:       input.Price,
+		Price:       price,
 		Stock:       uint(input.Stock),
+		MaxPerOrder: input.MaxPerOrder,
 		UserId:      user.ID,
-	})
-	return err
+	}
+	s.setSortPrice(product)
+	return s.Repo.CreateProduct(product)
 }
 
 func (s CatalogService) GetProducts() ([]*domain.Product, error) {
@@ -94,3 +220,293 @@ func (s CatalogService) GetProducts() ([]*domain.Product, error) {
 	}
 	return products, nil
 }
+
+// ListProducts returns one page of products matching the listing filters together with its pagination metadata
+func (s CatalogService) ListProducts(input dto.ProductListQuery) ([]*domain.Product, dto.PageMeta, error) {
+	q, err := s.buildProductQuery(input)
+	if err != nil {
+		return nil, dto.PageMeta{}, err
+	}
+
+	page, err := s.Repo.FindProductsByQuery(q)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, dto.PageMeta{}, ErrInvalidQuery
+		}
+		return nil, dto.PageMeta{}, errors.New("could not fetch products")
+	}
+
+	meta := dto.PageMeta{
+		Limit:      q.Limit,
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	}
+	if q.Cursor == "" {
+		meta.Page = q.Page
+	}
+	return page.Products, meta, nil
+}
+
+// SearchProducts runs a ranked full-text search over product names and descriptions, narrowed by the listing filters
+func (s CatalogService) SearchProducts(input dto.ProductSearchQuery) ([]*repository.ProductSearchHit, dto.PageMeta, error) {
+	input.Cursor = ""
+	q, err := s.buildProductQuery(input.ProductListQuery)
+	if err != nil {
+		return nil, dto.PageMeta{}, err
+	}
+
+	page, err := s.Repo.SearchProducts(repository.ProductSearch{
+		Terms:  input.Q,
+		Filter: q,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrEmptySearch) {
+			return nil, dto.PageMeta{}, ErrInvalidQuery
+		}
+		return nil, dto.PageMeta{}, errors.New("could not search products")
+	}
+
+	return page.Hits, dto.PageMeta{
+		Page:  q.Page,
+		Limit: q.Limit,
+		Total: page.Total,
+		Fuzzy: page.Fuzzy,
+	}, nil
+}
+
+func (s CatalogService) buildProductQuery(input dto.ProductListQuery) (repository.ProductQuery, error) {
+	q := repository.ProductQuery{
+		SellerId:    input.SellerId,
+		InStockOnly: input.InStock,
+		Page:        input.Page,
+		Limit:       input.Limit,
+		Cursor:      input.Cursor,
+	}
+
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Limit < 1 {
+		q.Limit = defaultPageLimit
+	}
+	if q.Limit > maxPageLimit {
+		q.Limit = maxPageLimit
+	}
+	var err error
+	if q.MinPrice, err = s.priceBound(input.MinPrice, input.Currency); err != nil {
+		return q, err
+	}
+	if q.MaxPrice, err = s.priceBound(input.MaxPrice, input.Currency); err != nil {
+		return q, err
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return q, ErrInvalidQuery
+	}
+
+	switch input.Sort {
+	case "", repository.SortByCreatedAt, repository.SortByPrice, repository.SortByName:
+		q.SortBy = input.Sort
+	default:
+		return q, ErrInvalidQuery
+	}
+	switch input.Order {
+	case "", "asc":
+	case "desc":
+		q.SortDesc = true
+	default:
+		return q, ErrInvalidQuery
+	}
+
+	if input.CategoryId > 0 {
+		q.CategoryIds = []uint{input.CategoryId}
+		if input.IncludeSubcategories {
+			ids, err := s.Repo.FindCategoryDescendantIds(input.CategoryId)
+			if err != nil {
+				return q, err
+			}
+			q.CategoryIds = ids
+		}
+	}
+	return q, nil
+}
+
+func (s CatalogService) GetProductById(id int) (*domain.Product, error) {
+	product, err := s.Repo.FindProductByID(id)
+	if err != nil {
+		return nil, productLookupError(err)
+	}
+	return product, nil
+}
+
+// productLookupError tells a missing product apart from a failed lookup, which is not the client's fault
+func productLookupError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrProductNotFound
+	}
+	return errors.New("could not fetch product")
+}
+
+func (s CatalogService) GetSellerProducts(user domain.User) ([]*domain.Product, error) {
+	products, err := s.Repo.FindSellerProducts(int(user.ID))
+	if err != nil {
+		return nil, errors.New("could not fetch seller products")
+	}
+	return products, nil
+}
+
+// GetSellerProduct returns the product only when it belongs to the given seller
+func (s CatalogService) GetSellerProduct(id int, user domain.User) (*domain.Product, error) {
+	product, err := s.GetProductById(id)
+	if err != nil {
+		return nil, err
+	}
+	if product.UserId != user.ID {
+		return nil, ErrProductNotOwned
+	}
+	return product, nil
+}
+
+func (s CatalogService) EditProduct(id int, input dto.CreateProductRequest, user domain.User) (*domain.Product, error) {
+	product, err := s.GetSellerProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if !validProductInput(input) {
+		return nil, ErrInvalidProduct
+	}
+	err = s.checkCategory(input.CategoryId)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkExternalSku(user.ID, product.ID, input.ExternalSku)
+	if err != nil {
+		return nil, err
+	}
+	price, err := s.sellerPrice(input.Price, user)
+	if err != nil {
+		return nil, err
+	}
+
+	product.Name = input.Name
+	product.ExternalSku = input.ExternalSku
+	product.Description = input.Description
+	product.CategoryId = input.CategoryId
+	product.ImageUrl = input.ImageUrl
+	product.Price = price
+	s.setSortPrice(product)
+	product.MaxPerOrder = input.MaxPerOrder
+	// stock of products with options is the total of their variants
+	if !product.HasOptions() {
+		product.Stock = uint(input.Stock)
+	}
+
+	return s.Repo.EditProduct(product)
+}
+
+func (s CatalogService) UpdateProductStock(id int, input dto.UpdateStockRequest, user domain.User) (*domain.Product, error) {
+	product, err := s.GetSellerProduct(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if input.Stock < 0 {
+		return nil, ErrInvalidStock
+	}
+	if product.HasOptions() {
+		return nil, ErrStockManagedByVariants
+	}
+
+	product.Stock = uint(input.Stock)
+
+	return s.Repo.EditProduct(product)
+}
+
+func (s CatalogService) DeleteProduct(id int, user domain.User) error {
+	product, err := s.GetSellerProduct(id, user)
+	if err != nil {
+		return err
+	}
+	err = s.Repo.DeleteProduct(product)
+	if err != nil {
+		return err
+	}
+	for _, img := range product.Images {
+		s.removeFiles(img.Key, img.ThumbnailKey)
+	}
+	return nil
+}
+
+// checkExternalSku makes sure no other product of the seller uses the sku
+func (s CatalogService) checkExternalSku(sellerId uint, productId uint, sku string) error {
+	if len(sku) == 0 {
+		return nil
+	}
+	existing, err := s.Repo.FindSellerProductBySku(sellerId, sku)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != productId {
+		return ErrDuplicateExternalSku
+	}
+	return nil
+}
+
+// checkCategory makes sure products are only filed under a category that exists
+func (s CatalogService) checkCategory(id uint) error {
+	_, err := s.Repo.FindCategoryByID(int(id))
+	if err != nil {
+		return ErrUnknownCategory
+	}
+	return nil
+}
+
+func validProductInput(input dto.CreateProductRequest) bool {
+	return len(input.Name) > 0 && input.CategoryId > 0 && !input.Price.IsNegative() && input.Stock >= 0
+}
+
+// sellerCurrency is the currency the seller prices their products in
+func (s CatalogService) sellerCurrency(user domain.User) string {
+	return userCurrency(s.URepo, s.Rates, s.Config, user.ID)
+}
+
+// sellerPrice puts the requested price in the seller's currency, a request in another currency is refused
+func (s CatalogService) sellerPrice(input money.Money, user domain.User) (money.Money, error) {
+	currency := s.sellerCurrency(user)
+	if input.IsSet() {
+		code, err := money.Normalize(input.Currency)
+		if err != nil || code != currency {
+			return money.Money{}, errors.Wrapf(ErrPriceCurrency, "your currency is %s", currency)
+		}
+	}
+	return money.New(input.Amount, currency), nil
+}
+
+// setSortPrice keeps the price in the default currency that listings filter and sort on
+func (s CatalogService) setSortPrice(product *domain.Product) {
+	price, err := s.Rates.Convert(product.Price, s.Config.Currency)
+	if err != nil {
+		log.Printf("error on converting the price of product %d %v", product.ID, err)
+		return
+	}
+	product.SortPrice = price.Amount
+}
+
+// priceBound turns a decimal price filter in the currency into minor units of the default currency
+func (s CatalogService) priceBound(value string, currency string) (*int64, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	if len(currency) == 0 {
+		currency = s.Config.Currency
+	}
+	price, err := money.Parse(value, currency)
+	if err != nil {
+		return nil, ErrInvalidQuery
+	}
+	if price, err = s.Rates.Convert(price, s.Config.Currency); err != nil {
+		return nil, errors.Wrap(ErrInvalidQuery, err.Error())
+	}
+	return &price.Amount, nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+)
+
+// GetStockHistory returns a page of the product's stock ledger, newest first
+func (s CatalogService) GetStockHistory(productId int, input dto.PageQuery, user domain.User) ([]*domain.StockMovement, dto.PageMeta, error) {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return nil, dto.PageMeta{}, err
+	}
+	page, limit := pageBounds(input)
+
+	movements, total, err := s.Repo.FindStockMovements(product.ID, page, limit)
+	if err != nil {
+		return nil, dto.PageMeta{}, err
+	}
+	return movements, dto.PageMeta{Page: page, Limit: limit, Total: total}, nil
+}
+
+// ReconcileStock recomputes each variant's stock from the ledger and reports any drift
+func (s CatalogService) ReconcileStock(productId int, user domain.User) ([]repository.StockDrift, error) {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ReconcileStock(product.ID)
+}
+
+// pageBounds applies the default and maximum page size to a page query
+func pageBounds(input dto.PageQuery) (int, int) {
+	page, limit := input.Page, input.Limit
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return page, limit
+}
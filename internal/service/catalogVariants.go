@@ -0,0 +1,251 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/money"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrVariantNotFound        = errors.New("variant does not exist")
+	ErrInvalidVariant         = errors.New("variant needs a sku, at least one option and a non negative price and stock")
+	ErrDuplicateSku           = errors.New("sku is already used by another variant")
+	ErrDuplicateVariant       = errors.New("a variant with the same options already exists")
+	ErrLastVariant            = errors.New("a product must keep at least one variant")
+	ErrOptionNotFound         = errors.New("product option does not exist")
+	ErrOptionInUse            = errors.New("product option is still used by a variant")
+	ErrInvalidOption          = errors.New("option name is required")
+	ErrStockManagedByVariants = errors.New("product has variants, update the stock of each variant instead")
+)
+
+func (s CatalogService) GetProductVariants(productId int, user domain.User) (*dto.ProductVariantsResponse, error) {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return nil, err
+	}
+	options, err := s.Repo.FindProductOptions(product.ID)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := s.Repo.FindProductVariants(product.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ProductVariantsResponse{
+		Options:  options,
+		Variants: variants,
+	}, nil
+}
+
+// CreateVariant adds a variant to the product. The first variant of a product
+// without options takes over its default variant, so existing cart lines stay valid.
+func (s CatalogService) CreateVariant(productId int, input dto.CreateVariantRequest, user domain.User) (*domain.ProductVariant, error) {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return nil, err
+	}
+
+	variant := &domain.ProductVariant{ProductId: product.ID}
+	if !product.HasOptions() && len(product.Variants) == 1 {
+		variant = &product.Variants[0]
+		variant.IsDefault = false
+	}
+
+	options, err := s.applyVariantInput(product, variant, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if variant.ID > 0 {
+		return s.Repo.EditVariant(variant, options, user.ID)
+	}
+	err = s.Repo.CreateVariant(variant, options, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+func (s CatalogService) EditVariant(productId int, variantId int, input dto.CreateVariantRequest, user domain.User) (*domain.ProductVariant, error) {
+	product, variant, err := s.getSellerVariant(productId, variantId, user)
+	if err != nil {
+		return nil, err
+	}
+	options, err := s.applyVariantInput(product, variant, input)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.EditVariant(variant, options, user.ID)
+}
+
+func (s CatalogService) UpdateVariantStock(productId int, variantId int, input dto.UpdateStockRequest, user domain.User) (*domain.ProductVariant, error) {
+	_, variant, err := s.getSellerVariant(productId, variantId, user)
+	if err != nil {
+		return nil, err
+	}
+	if input.Stock < 0 {
+		return nil, ErrInvalidStock
+	}
+	variant.Stock = uint(input.Stock)
+	return s.Repo.EditVariant(variant, nil, user.ID)
+}
+
+func (s CatalogService) DeleteVariant(productId int, variantId int, user domain.User) error {
+	product, variant, err := s.getSellerVariant(productId, variantId, user)
+	if err != nil {
+		return err
+	}
+	if len(product.Variants) < 2 {
+		return ErrLastVariant
+	}
+	return s.Repo.DeleteVariant(variant)
+}
+
+func (s CatalogService) CreateOption(productId int, input dto.CreateOptionRequest, user domain.User) (*domain.ProductOption, error) {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(input.Name)
+	if len(name) == 0 {
+		return nil, ErrInvalidOption
+	}
+	for _, o := range product.Options {
+		if strings.EqualFold(o.Name, name) {
+			return nil, errors.New("product option already exists")
+		}
+	}
+
+	option := &domain.ProductOption{
+		ProductId: product.ID,
+		Name:      name,
+		Position:  input.Position,
+	}
+	for i, v := range input.Values {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		option.Values = append(option.Values, domain.ProductOptionValue{Value: v, Position: uint(i)})
+	}
+
+	err = s.Repo.CreateProductOption(option)
+	if err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+func (s CatalogService) DeleteOption(productId int, optionId int, user domain.User) error {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return err
+	}
+	for _, o := range product.Options {
+		if o.ID != uint(optionId) {
+			continue
+		}
+		for _, v := range product.Variants {
+			for _, ov := range v.OptionValues {
+				if ov.OptionId == o.ID {
+					return ErrOptionInUse
+				}
+			}
+		}
+		return s.Repo.DeleteProductOption(&o)
+	}
+	return ErrOptionNotFound
+}
+
+func (s CatalogService) getSellerVariant(productId int, variantId int, user domain.User) (*domain.Product, *domain.ProductVariant, error) {
+	product, err := s.GetSellerProduct(productId, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range product.Variants {
+		if product.Variants[i].ID == uint(variantId) {
+			return product, &product.Variants[i], nil
+		}
+	}
+	return nil, nil, ErrVariantNotFound
+}
+
+// applyVariantInput validates the whole request and copies it onto the variant. Nothing is written,
+// the options it returns are resolved to option values when the variant is saved.
+func (s CatalogService) applyVariantInput(product *domain.Product, variant *domain.ProductVariant, input dto.CreateVariantRequest) ([]repository.VariantOption, error) {
+	sku := strings.TrimSpace(input.Sku)
+	if len(sku) == 0 || len(input.Options) == 0 || input.Stock < 0 || input.Price.IsNegative() {
+		return nil, ErrInvalidVariant
+	}
+	// a variant price overrides the product price in the same currency
+	price := money.Money{}
+	if input.Price.IsSet() {
+		code, err := money.Normalize(input.Price.Currency)
+		if err != nil || code != product.Price.Currency {
+			return nil, errors.Wrapf(ErrPriceCurrency, "the product is priced in %s", product.Price.Currency)
+		}
+		price = money.New(input.Price.Amount, code)
+	}
+	existing, err := s.Repo.FindVariantBySku(sku)
+	if err == nil && existing.ID != variant.ID {
+		return nil, ErrDuplicateSku
+	}
+
+	options := make([]repository.VariantOption, 0, len(input.Options))
+	for name, value := range input.Options {
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if len(name) == 0 || len(value) == 0 {
+			return nil, ErrInvalidVariant
+		}
+		options = append(options, repository.VariantOption{Name: name, Value: value})
+	}
+	// new options are created in name order, so the variant title does not depend on map order
+	sort.Slice(options, func(i, j int) bool {
+		return strings.ToLower(options[i].Name) < strings.ToLower(options[j].Name)
+	})
+
+	key := variantOptionsKey(options)
+	names := optionNames(product)
+	for _, other := range product.Variants {
+		if other.ID == variant.ID || other.IsDefault {
+			continue
+		}
+		var taken []repository.VariantOption
+		for _, v := range other.OptionValues {
+			taken = append(taken, repository.VariantOption{Name: names[v.OptionId], Value: v.Value})
+		}
+		if variantOptionsKey(taken) == key {
+			return nil, ErrDuplicateVariant
+		}
+	}
+
+	variant.Sku = sku
+	variant.Price = price
+	variant.Stock = uint(input.Stock)
+	variant.ImageUrl = input.ImageUrl
+	return options, nil
+}
+
+// optionNames maps the option ids of the product to their names
+func optionNames(product *domain.Product) map[uint]string {
+	names := make(map[uint]string, len(product.Options))
+	for _, o := range product.Options {
+		names[o.ID] = o.Name
+	}
+	return names
+}
+
+// variantOptionsKey identifies a combination of option values regardless of order and case
+func variantOptionsKey(options []repository.VariantOption) string {
+	pairs := make([]string, 0, len(options))
+	for _, o := range options {
+		pairs = append(pairs, strings.ToLower(o.Name)+"="+strings.ToLower(o.Value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\n")
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"ecommerce-app/config"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/money"
+
+	"github.com/pkg/errors"
+)
+
+var ErrUnsupportedCurrency = errors.New("currency is not supported, no exchange rate is loaded for it")
+
+// userCurrency is the currency the user picked, sellers price their products in it and buyers pay in it.
+// Users who did not pick one, or picked one that is no longer in the rate table, get the default currency.
+func userCurrency(repo repository.UserRepository, rates *money.Rates, config config.AppConfig, userId uint) string {
+	if userId > 0 {
+		user, err := repo.FindUserById(userId)
+		if err == nil && len(user.Currency) > 0 && rates.Supports(user.Currency) {
+			return user.Currency
+		}
+	}
+	return config.Currency
+}
+
+// supportedCurrency normalizes a currency code the user picked and checks that prices can be converted to it
+func supportedCurrency(rates *money.Rates, code string) (string, error) {
+	normalized, err := money.Normalize(code)
+	if err != nil || !rates.Supports(normalized) {
+		return "", errors.Wrap(ErrUnsupportedCurrency, code)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/notification"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrOrderShipped        = repository.ErrOrderShipped
+	ErrInvalidCancellation = repository.ErrInvalidCancellation
+	ErrRefundNotFound      = repository.ErrRefundNotFound
+	ErrRefundNotRetryable  = repository.ErrRefundNotRetryable
+)
+
+// CancelOrder cancels the buyer's order, or only the given quantities of its items, as long as the
+// affected sellers have not shipped. Cancelled items go back in stock, paid items are refunded
+// through the payment layer and every affected seller is told.
+func (s OrderService) CancelOrder(orderId uint, input dto.CancelOrderRequest, buyer domain.User) (*domain.Order, error) {
+	order, err := s.Repo.FindOrderByID(orderId)
+	if err != nil || order.UserId != buyer.ID {
+		return nil, ErrOrderNotFound
+	}
+
+	lines := map[uint]uint{}
+	for _, item := range input.Items {
+		if item.OrderItemId == 0 || item.Qty == 0 {
+			return nil, errors.Wrap(ErrInvalidCancellation, "every item needs an order_item_id and a qty")
+		}
+		lines[item.OrderItemId] += item.Qty
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if len(reason) == 0 {
+		reason = "cancelled by buyer"
+	}
+
+	order, cancellations, err := s.cancel(orderId, lines, buyer.ID, reason)
+	if err != nil {
+		return nil, err
+	}
+	s.notifySellers(order, cancellations, "the buyer")
+
+	return s.Repo.FindOrderByID(orderId)
+}
+
+// cancel is the only way orders and fulfilment groups get cancelled: lines are cancelled as in
+// CancelOrder, paid items are refunded through the payment layer, refunds that fail are kept failed
+// for an admin to retry with RetryRefund, and once the order amount changed
+// or the whole order is cancelled, payments still waiting for the buyer are cancelled too
+func (s OrderService) cancel(orderId uint, lines map[uint]uint, actorId uint, reason string) (*domain.Order, []repository.Cancellation, error) {
+	order, cancellations, err := s.Repo.CancelOrder(orderId, lines, actorId, reason)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, c := range cancellations {
+		if c.Refund == nil {
+			continue
+		}
+		if err = s.Payments.RefundPayment(c.Refund); err != nil {
+			log.Printf("error refunding order %d: %v", orderId, err)
+		}
+		if err = s.Repo.UpdateRefund(c.Refund); err != nil {
+			log.Printf("error saving refund of order %d: %v", orderId, err)
+		}
+	}
+	// a payment still waiting for the buyer must not go through for a cancelled order, nor charge
+	// the amount from before the cancellation, the buyer checks out again for what is left
+	repriced := order.Status == domain.OrderStatusCancelled
+	for _, c := range cancellations {
+		repriced = repriced || c.Repriced
+	}
+	if repriced {
+		s.Payments.CancelOpenPayments(order.ID)
+	}
+	return order, cancellations, nil
+}
+
+// RetryRefund issues a failed refund of cancelled items again, e.g. once the payment provider is back
+func (s OrderService) RetryRefund(id uint) (*domain.Refund, error) {
+	return s.Repo.RetryRefund(id, s.Payments.RefundPayment)
+}
+
+// cancelGroup cancels every item of the fulfilment group that is still open
+func (s OrderService) cancelGroup(order *domain.Order, group *domain.FulfilmentGroup, actorId uint, reason string) (*domain.Order, error) {
+	lines := map[uint]uint{}
+	for _, item := range group.Items {
+		if item.Qty > item.CancelledQty {
+			lines[item.ID] = item.Qty - item.CancelledQty
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.Wrapf(ErrInvalidOrderTransition, "%s to %s", group.Status, domain.OrderStatusCancelled)
+	}
+	order, cancellations, err := s.cancel(order.ID, lines, actorId, reason)
+	if err != nil {
+		return nil, err
+	}
+	s.notifySellers(order, cancellations, "an admin")
+	return s.Repo.FindOrderByID(order.ID)
+}
+
+// notifySellers sends an SMS to every seller whose items were cancelled, failures are only logged
+func (s OrderService) notifySellers(order *domain.Order, cancellations []repository.Cancellation, by string) {
+	notificationClient := notification.NewNotificationClient(s.Config)
+
+	for _, c := range cancellations {
+		seller, err := s.URepo.FindUserById(c.SellerId)
+		if err != nil || len(seller.Phone) == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("Order %d: %s cancelled some of your items, please do not ship them", order.OrderRefNumber, by)
+		if c.Whole {
+			msg = fmt.Sprintf("Order %d has been cancelled by %s, please do not ship it", order.OrderRefNumber, by)
+		}
+		if err = notificationClient.SendSMS(seller.Phone, msg); err != nil {
+			log.Printf("error notifying seller %d of cancellation %v", c.SellerId, err)
+		}
+	}
+}
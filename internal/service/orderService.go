@@ -0,0 +1,146 @@
+package service
+
+import (
+	"ecommerce-app/config"
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/helper"
+	"ecommerce-app/internal/repository"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrOrderNotFound          = errors.New("order does not exist")
+	ErrOrderTransitionDenied  = errors.New("you are not allowed to move this order to that status")
+	ErrInvalidOrderTransition = repository.ErrInvalidOrderTransition
+)
+
+// the statuses each party may move an order to, the state machine decides from which status
+var (
+	buyerOrderStatuses  = []string{domain.OrderStatusCompleted}  // buyers cancel through CancelOrder
+	sellerOrderStatuses = []string{domain.OrderStatusProcessing} // shipped and delivered follow their shipments
+)
+
+// OrderService drives orders through their lifecycle
+type OrderService struct {
+	Repo     repository.OrderRepository
+	URepo    repository.UserRepository
+	Payments TransactionService
+	Auth     helper.Auth
+	Config   config.AppConfig
+}
+
+// ChangeOrderStatus moves the order to a new status on behalf of the actor.
+// Buyers can complete their orders and admins can make any transition the state machine allows,
+// both apply to every fulfilment group that can make it. Sellers move their own fulfilment group.
+// Cancelling goes through the same refunds as a buyer cancellation.
+func (s OrderService) ChangeOrderStatus(orderId uint, to string, actor domain.User, reason string) (*domain.Order, error) {
+	order, err := s.Repo.FindOrderByID(orderId)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	if !canChangeOrder(order, to, actor) {
+		// orders of other buyers are not disclosed
+		if actor.UserType != domain.ADMIN && order.UserId != actor.ID && !hasSellerItems(order, actor.ID) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, ErrOrderTransitionDenied
+	}
+	if actor.UserType != domain.ADMIN && order.UserId != actor.ID {
+		group := sellerGroup(order, actor.ID)
+		if group == nil {
+			return nil, ErrOrderNotFound
+		}
+		return s.Repo.TransitionGroup(group.ID, to, actor.ID, reason)
+	}
+	if to == domain.OrderStatusCancelled {
+		return s.cancelOrder(order, actor.ID, reason)
+	}
+	return s.Repo.TransitionOrder(order.ID, to, actor.ID, reason)
+}
+
+// ChangeGroupStatus lets an admin move a single fulfilment group of the order
+func (s OrderService) ChangeGroupStatus(orderId uint, groupId uint, to string, actor domain.User, reason string) (*domain.Order, error) {
+	order, err := s.Repo.FindOrderByID(orderId)
+	if err != nil {
+		return nil, ErrOrderNotFound
+	}
+	for i := range order.Groups {
+		group := &order.Groups[i]
+		if group.ID != groupId {
+			continue
+		}
+		if to == domain.OrderStatusCancelled {
+			return s.cancelGroup(order, group, actor.ID, cancelReason(reason, "cancelled by an admin"))
+		}
+		return s.Repo.TransitionGroup(group.ID, to, actor.ID, reason)
+	}
+	return nil, ErrOrderNotFound
+}
+
+// SystemOrderStatus moves the order on behalf of the platform, e.g. after a payment notification
+func (s OrderService) SystemOrderStatus(orderId uint, to string, reason string) (*domain.Order, error) {
+	if to == domain.OrderStatusCancelled {
+		order, _, err := s.cancel(orderId, nil, 0, cancelReason(reason, "cancelled by the platform"))
+		return order, err
+	}
+	return s.Repo.TransitionOrder(orderId, to, 0, reason)
+}
+
+// cancelOrder cancels every item of the order still open on behalf of an admin
+func (s OrderService) cancelOrder(order *domain.Order, actorId uint, reason string) (*domain.Order, error) {
+	order, cancellations, err := s.cancel(order.ID, nil, actorId, cancelReason(reason, "cancelled by an admin"))
+	if err != nil {
+		return nil, err
+	}
+	s.notifySellers(order, cancellations, "an admin")
+	return s.Repo.FindOrderByID(order.ID)
+}
+
+// cancelReason is the reason given for a cancellation, or fallback when none was given
+func cancelReason(reason string, fallback string) string {
+	if reason = strings.TrimSpace(reason); len(reason) > 0 {
+		return reason
+	}
+	return fallback
+}
+
+func sellerGroup(order *domain.Order, sellerId uint) *domain.FulfilmentGroup {
+	for i := range order.Groups {
+		if order.Groups[i].SellerId == sellerId {
+			return &order.Groups[i]
+		}
+	}
+	return nil
+}
+
+func canChangeOrder(order *domain.Order, to string, actor domain.User) bool {
+	switch {
+	case actor.UserType == domain.ADMIN:
+		return true
+	case order.UserId == actor.ID && containsStatus(buyerOrderStatuses, to):
+		return true
+	case hasSellerItems(order, actor.ID) && containsStatus(sellerOrderStatuses, to):
+		return true
+	}
+	return false
+}
+
+func hasSellerItems(order *domain.Order, sellerId uint) bool {
+	for _, item := range order.Items {
+		if item.SellerId == sellerId {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/repository"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNoSellerItems      = repository.ErrNoSellerItems
+	ErrShipmentNotFound   = repository.ErrShipmentNotFound
+	ErrShipmentNotAllowed = repository.ErrShipmentNotAllowed
+	ErrInvalidShipment    = repository.ErrInvalidShipment
+	ErrShipmentStatus     = repository.ErrShipmentStatus
+)
+
+// CreateShipment records a parcel of the seller's items in the order, see OrderRepository.CreateShipment
+func (s OrderService) CreateShipment(orderId uint, input dto.CreateShipmentRequest, seller domain.User) (*domain.Order, error) {
+	carrier := strings.TrimSpace(input.Carrier)
+	tracking := strings.TrimSpace(input.TrackingNumber)
+	if len(carrier) == 0 || len(tracking) == 0 {
+		return nil, errors.Wrap(ErrInvalidShipment, "carrier and tracking number are required")
+	}
+
+	shipment := &domain.Shipment{
+		SellerId:       seller.ID,
+		Carrier:        carrier,
+		TrackingNumber: tracking,
+		TrackingUrl:    strings.TrimSpace(input.TrackingUrl),
+	}
+	for _, item := range input.Items {
+		shipment.Items = append(shipment.Items, domain.ShipmentItem{
+			OrderItemId: item.OrderItemId,
+			Qty:         item.Qty,
+		})
+	}
+	return s.Repo.CreateShipment(orderId, shipment)
+}
+
+// UpdateShipment corrects the carrier details of the seller's shipment or moves it to in transit or delivered
+func (s OrderService) UpdateShipment(shipmentId uint, input dto.UpdateShipmentRequest, seller domain.User) (*domain.Order, error) {
+	switch input.Status {
+	case "", domain.ShipmentStatusShipped, domain.ShipmentStatusInTransit, domain.ShipmentStatusDelivered:
+	default:
+		return nil, errors.Wrap(ErrInvalidShipment, "status must be in_transit or delivered")
+	}
+	return s.Repo.UpdateShipment(domain.Shipment{
+		ID:             shipmentId,
+		SellerId:       seller.ID,
+		Carrier:        strings.TrimSpace(input.Carrier),
+		TrackingNumber: strings.TrimSpace(input.TrackingNumber),
+		TrackingUrl:    strings.TrimSpace(input.TrackingUrl),
+		Status:         input.Status,
+	})
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/pkg/payment"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("payment provider is not configured")
+	ErrInvalidSignature = payment.ErrInvalidSignature
+	ErrInvalidWebhook   = errors.New("webhook payload is not valid")
+)
+
+// a late event must not move a payment out of these statuses
+var finalPaymentStatuses = []string{payment.StatusSucceeded, payment.StatusCanceled}
+
+// HandleWebhook verifies the signature of a provider event, records it and applies it to the payment
+// and its order. Events are recorded once per provider event id and an event already applied is only
+// acknowledged, so redelivered events change payments and orders once. A failed event is kept
+// unprocessed and applied again when the provider retries it.
+func (s TransactionService) HandleWebhook(provider string, signature string, payload []byte) error {
+	secret, ok := s.Config.WebhookSecrets[provider]
+	if !ok || provider != s.Provider.Name() {
+		return ErrUnknownProvider
+	}
+	if err := payment.VerifySignature(secret, signature, payload, time.Now()); err != nil {
+		return ErrInvalidSignature
+	}
+	event, err := s.Provider.ParseEvent(payload)
+	if err != nil {
+		return errors.Wrap(ErrInvalidWebhook, err.Error())
+	}
+
+	record := &domain.PaymentEvent{
+		Provider:  provider,
+		EventId:   event.ID,
+		Type:      event.Type,
+		PaymentId: event.Intent.ID,
+		Payload:   string(payload),
+	}
+	return s.Repo.ProcessPaymentEvent(record, func() error {
+		return s.applyEvent(provider, event)
+	})
+}
+
+func (s TransactionService) applyEvent(provider string, event *payment.Event) error {
+	p, err := s.Repo.FindPaymentByIntent(provider, event.Intent.ID)
+	if err != nil {
+		// not a payment of this app, retrying would not change that
+		log.Printf("payment_err: event %s is for unknown intent %s", event.ID, event.Intent.ID)
+		return nil
+	}
+	if containsStatus(finalPaymentStatuses, p.Status) && p.Status != event.Intent.Status {
+		log.Printf("payment_err: ignoring %s for payment %d, it is already %s", event.Type, p.ID, p.Status)
+		return nil
+	}
+	_, err = s.applyIntent(p, event.Intent)
+	if errors.Is(err, ErrPaymentDeclined) {
+		return nil
+	}
+	return err
+}
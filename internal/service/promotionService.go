@@ -0,0 +1,168 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/helper"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/money"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("promotion does not exist")
+	ErrPromotionNotOwned = errors.New("you are not allowed to manage this promotion")
+	ErrInvalidPromotion  = errors.New("promotion is not valid")
+	ErrDuplicateCoupon   = errors.New("coupon code is already in use")
+)
+
+type PromotionService struct {
+	Repo  repository.PromotionRepository
+	CRepo repository.CatalogRepository
+	Auth  helper.Auth
+	Rates *money.Rates
+}
+
+// GetPromotions lists the seller's own promotions, admins see every promotion
+func (s PromotionService) GetPromotions(user domain.User) ([]*domain.Promotion, error) {
+	if user.UserType == domain.ADMIN {
+		return s.Repo.FindPromotions(0)
+	}
+	return s.Repo.FindPromotions(user.ID)
+}
+
+func (s PromotionService) GetPromotion(id int, user domain.User) (*domain.Promotion, error) {
+	promotion, err := s.Repo.FindPromotionByID(uint(id))
+	if err != nil {
+		return nil, ErrPromotionNotFound
+	}
+	if user.UserType != domain.ADMIN && promotion.CreatedBy != user.ID {
+		return nil, ErrPromotionNotOwned
+	}
+	return promotion, nil
+}
+
+func (s PromotionService) CreatePromotion(input dto.CreatePromotionRequest, user domain.User) (*domain.Promotion, error) {
+	promotion := &domain.Promotion{CreatedBy: user.ID, Active: true}
+	if err := s.fillPromotion(promotion, input, user); err != nil {
+		return nil, err
+	}
+	if _, err := s.Repo.FindPromotionByCode(promotion.Code); err == nil {
+		return nil, ErrDuplicateCoupon
+	}
+	err := s.Repo.CreatePromotion(promotion)
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (s PromotionService) EditPromotion(id int, input dto.CreatePromotionRequest, user domain.User) (*domain.Promotion, error) {
+	promotion, err := s.GetPromotion(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.fillPromotion(promotion, input, user); err != nil {
+		return nil, err
+	}
+	if existing, err := s.Repo.FindPromotionByCode(promotion.Code); err == nil && existing.ID != promotion.ID {
+		return nil, ErrDuplicateCoupon
+	}
+	err = s.Repo.UpdatePromotion(promotion)
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (s PromotionService) DeletePromotion(id int, user domain.User) error {
+	promotion, err := s.GetPromotion(id, user)
+	if err != nil {
+		return err
+	}
+	return s.Repo.DeletePromotion(promotion.ID)
+}
+
+// fillPromotion validates the request and copies it onto the promotion.
+// Promotions created by sellers are always limited to their own products.
+func (s PromotionService) fillPromotion(p *domain.Promotion, input dto.CreatePromotionRequest, user domain.User) error {
+	code := strings.ToUpper(strings.TrimSpace(input.Code))
+	if len(code) == 0 || len(code) > 32 || strings.ContainsAny(code, " \t\n") {
+		return errors.Wrap(ErrInvalidPromotion, "code must be 1 to 32 characters without spaces")
+	}
+
+	switch input.Type {
+	case domain.PromotionPercentage:
+		if input.Value <= 0 || input.Value > 100 {
+			return errors.Wrap(ErrInvalidPromotion, "percentage must be between 0 and 100")
+		}
+	case domain.PromotionFixed:
+		if input.Amount.Amount <= 0 {
+			return errors.Wrap(ErrInvalidPromotion, "amount off must be positive")
+		}
+	case domain.PromotionBuyXGetY:
+		if input.BuyQty == 0 || input.GetQty == 0 {
+			return errors.Wrap(ErrInvalidPromotion, "buy_qty and get_qty are required")
+		}
+	case domain.PromotionFreeShipping:
+	default:
+		return errors.Wrap(ErrInvalidPromotion, "type must be percentage, fixed, free_shipping or buy_x_get_y")
+	}
+
+	if input.MinSpend.IsNegative() {
+		return errors.Wrap(ErrInvalidPromotion, "minimum spend cannot be negative")
+	}
+	// amounts are converted to the buyer's currency when the coupon is applied
+	amount, err := s.promotionAmount(input.Amount, input.Type == domain.PromotionFixed)
+	if err != nil {
+		return err
+	}
+	minSpend, err := s.promotionAmount(input.MinSpend, input.MinSpend.Amount > 0)
+	if err != nil {
+		return err
+	}
+	if input.StartsAt != nil && input.EndsAt != nil && !input.EndsAt.After(*input.StartsAt) {
+		return errors.Wrap(ErrInvalidPromotion, "ends_at must be after starts_at")
+	}
+	if input.CategoryId > 0 {
+		if _, err := s.CRepo.FindCategoryByID(int(input.CategoryId)); err != nil {
+			return ErrCategoryNotFound
+		}
+	}
+
+	p.Code = code
+	p.Description = input.Description
+	p.Type = input.Type
+	p.Value = input.Value
+	p.Amount = amount
+	p.BuyQty = input.BuyQty
+	p.GetQty = input.GetQty
+	p.MinSpend = minSpend
+	p.CategoryId = input.CategoryId
+	p.SellerId = input.SellerId
+	if user.UserType != domain.ADMIN {
+		p.SellerId = user.ID
+	}
+	p.StartsAt = input.StartsAt
+	p.EndsAt = input.EndsAt
+	p.UsageLimit = input.UsageLimit
+	p.PerUserLimit = input.PerUserLimit
+	if input.Active != nil {
+		p.Active = *input.Active
+	}
+	return nil
+}
+
+// promotionAmount checks the currency of an amount of the promotion, unused amounts are left unset
+func (s PromotionService) promotionAmount(m money.Money, used bool) (money.Money, error) {
+	if !used {
+		return money.Money{}, nil
+	}
+	code, err := supportedCurrency(s.Rates, m.Currency)
+	if err != nil {
+		return money.Money{}, errors.Wrap(ErrInvalidPromotion, err.Error())
+	}
+	return money.New(m.Amount, code), nil
+}
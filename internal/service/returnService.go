@@ -0,0 +1,133 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/helper"
+	"ecommerce-app/internal/repository"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const maxReturnPhotos = 5
+
+var (
+	ErrReturnNotFound      = repository.ErrReturnNotFound
+	ErrReturnNotAllowed    = repository.ErrReturnNotAllowed
+	ErrInvalidReturn       = repository.ErrInvalidReturn
+	ErrReturnStatus        = repository.ErrReturnStatus
+	ErrInvalidRefundAmount = repository.ErrInvalidRefundAmount
+	ErrRefundFailed        = repository.ErrRefundFailed
+	ErrTooManyReturnPhotos = fmt.Errorf("a return can have at most %d photos", maxReturnPhotos)
+)
+
+// ReturnService takes delivered items back from buyers and refunds them
+type ReturnService struct {
+	Repo     repository.ReturnRepository
+	Payments TransactionService
+	Catalog  CatalogService // stores the photos like product images
+	Auth     helper.Auth
+}
+
+// RequestReturn opens a return for some quantity of a delivered item of the buyer's order
+func (s ReturnService) RequestReturn(orderId uint, input dto.CreateReturnRequest, buyer domain.User) (*domain.ReturnRequest, error) {
+	reason := strings.TrimSpace(input.Reason)
+	if input.OrderItemId == 0 || input.Qty == 0 || len(reason) == 0 {
+		return nil, errors.Wrap(ErrInvalidReturn, "order_item_id, qty and reason are required")
+	}
+	resolution := input.Resolution
+	if len(resolution) == 0 {
+		resolution = domain.ReturnResolutionRefund
+	}
+	if resolution != domain.ReturnResolutionRefund && resolution != domain.ReturnResolutionReplacement {
+		return nil, errors.Wrap(ErrInvalidReturn, "resolution must be refund or replacement")
+	}
+
+	e := &domain.ReturnRequest{
+		OrderId:     orderId,
+		OrderItemId: input.OrderItemId,
+		UserId:      buyer.ID,
+		Qty:         input.Qty,
+		Reason:      reason,
+		Resolution:  resolution,
+	}
+	if err := s.Repo.CreateReturn(e); err != nil {
+		return nil, err
+	}
+	return s.Repo.FindReturnByID(e.ID)
+}
+
+// AddReturnPhoto stores a photo of the returned item while the seller has not reviewed the return yet
+func (s ReturnService) AddReturnPhoto(id uint, data []byte, buyer domain.User) (*domain.ReturnPhoto, error) {
+	e, err := s.Repo.FindReturnByID(id)
+	if err != nil || e.UserId != buyer.ID {
+		return nil, ErrReturnNotFound
+	}
+	if e.Status != domain.ReturnStatusRequested {
+		return nil, errors.Wrap(ErrReturnStatus, e.Status)
+	}
+	if len(e.Photos) >= maxReturnPhotos {
+		return nil, ErrTooManyReturnPhotos
+	}
+
+	stored, err := s.Catalog.storeImage(fmt.Sprintf("returns/%d", e.ID), data)
+	if err != nil {
+		return nil, err
+	}
+	photo := &domain.ReturnPhoto{
+		ReturnId:     e.ID,
+		Key:          stored.key,
+		ThumbnailKey: stored.thumbnailKey,
+		Url:          s.Catalog.mediaUrl(stored.key),
+		ThumbnailUrl: s.Catalog.mediaUrl(stored.thumbnailKey),
+	}
+	if err = s.Repo.CreateReturnPhoto(photo); err != nil {
+		s.Catalog.removeFiles(stored.key, stored.thumbnailKey)
+		return nil, err
+	}
+	return photo, nil
+}
+
+// GetBuyerReturns lists the buyer's returns, newest first
+func (s ReturnService) GetBuyerReturns(buyer domain.User, input dto.ReturnListQuery) ([]*domain.ReturnRequest, dto.PageMeta, error) {
+	page, limit := pageBounds(input.PageQuery)
+	returns, total, err := s.Repo.FindReturns(buyer.ID, 0, input.Status, page, limit)
+	if err != nil {
+		return nil, dto.PageMeta{}, err
+	}
+	return returns, dto.PageMeta{Page: page, Limit: limit, Total: total}, nil
+}
+
+// GetSellerReturns lists the returns of the seller's items, newest first
+func (s ReturnService) GetSellerReturns(seller domain.User, input dto.ReturnListQuery) ([]*domain.ReturnRequest, dto.PageMeta, error) {
+	page, limit := pageBounds(input.PageQuery)
+	returns, total, err := s.Repo.FindReturns(0, seller.ID, input.Status, page, limit)
+	if err != nil {
+		return nil, dto.PageMeta{}, err
+	}
+	return returns, dto.PageMeta{Page: page, Limit: limit, Total: total}, nil
+}
+
+func (s ReturnService) ReviewReturn(id uint, input dto.ReviewReturnRequest, seller domain.User) (*domain.ReturnRequest, error) {
+	switch input.Status {
+	case domain.ReturnStatusApproved, domain.ReturnStatusRejected:
+	default:
+		return nil, errors.Wrap(ErrInvalidReturn, "status must be approved or rejected")
+	}
+	return s.Repo.ReviewReturn(id, seller.ID, input.Status == domain.ReturnStatusApproved, strings.TrimSpace(input.Note))
+}
+
+func (s ReturnService) ReceiveReturn(id uint, input dto.ReceiveReturnRequest, seller domain.User) (*domain.ReturnRequest, error) {
+	return s.Repo.ReceiveReturn(id, seller.ID, input.Restock)
+}
+
+// RefundReturn refunds a received return in full or in part through the payment layer,
+// the return stays received when the payment layer fails so the seller can try again
+func (s ReturnService) RefundReturn(id uint, input dto.RefundReturnRequest, seller domain.User) (*domain.ReturnRequest, error) {
+	if input.Amount.IsNegative() {
+		return nil, ErrInvalidRefundAmount
+	}
+	return s.Repo.RefundReturn(id, seller.ID, input.Amount, s.Payments.RefundPayment)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/helper"
+	"ecommerce-app/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidReview     = errors.New("rating must be between 1 and 5 and the review needs a title")
+	ErrNotPurchased      = errors.New("only buyers with a completed order for this product can review it")
+	ErrAlreadyReviewed   = errors.New("you have already reviewed this product")
+	ErrReviewNotFound    = errors.New("review does not exist")
+	ErrAlreadyReplied    = errors.New("this review already has a reply")
+	ErrInvalidReply      = errors.New("reply cannot be empty")
+	ErrOwnReviewVote     = errors.New("you cannot vote on your own review")
+	ErrInvalidReviewSort = errors.New("sort must be newest or helpful")
+)
+
+type ReviewService struct {
+	Repo  repository.ReviewRepository
+	CRepo repository.CatalogRepository
+	Auth  helper.Auth
+}
+
+func (s ReviewService) CreateReview(productId int, input dto.CreateReviewRequest, user domain.User) (*domain.Review, error) {
+	product, err := s.CRepo.FindProductByID(productId)
+	if err != nil {
+		return nil, productLookupError(err)
+	}
+	title := strings.TrimSpace(input.Title)
+	if input.Rating < 1 || input.Rating > 5 || len(title) == 0 {
+		return nil, ErrInvalidReview
+	}
+
+	purchased, err := s.Repo.HasCompletedPurchase(user.ID, product.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !purchased {
+		return nil, ErrNotPurchased
+	}
+	if _, err = s.Repo.FindUserReview(product.ID, user.ID); err == nil {
+		return nil, ErrAlreadyReviewed
+	}
+
+	review := &domain.Review{
+		ProductId: product.ID,
+		UserId:    user.ID,
+		Rating:    input.Rating,
+		Title:     title,
+		Body:      strings.TrimSpace(input.Body),
+	}
+	err = s.Repo.CreateReview(review)
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (s ReviewService) GetReviews(productId int, input dto.ReviewListQuery) ([]*domain.Review, dto.PageMeta, error) {
+	product, err := s.CRepo.FindProductByID(productId)
+	if err != nil {
+		return nil, dto.PageMeta{}, productLookupError(err)
+	}
+	sort := input.Sort
+	if len(sort) == 0 {
+		sort = repository.ReviewSortNewest
+	}
+	if sort != repository.ReviewSortNewest && sort != repository.ReviewSortHelpful {
+		return nil, dto.PageMeta{}, ErrInvalidReviewSort
+	}
+	page, limit := pageBounds(input.PageQuery)
+
+	reviews, total, err := s.Repo.FindReviews(product.ID, sort, page, limit)
+	if err != nil {
+		return nil, dto.PageMeta{}, err
+	}
+	return reviews, dto.PageMeta{Page: page, Limit: limit, Total: total}, nil
+}
+
+func (s ReviewService) MarkHelpful(productId int, reviewId int, user domain.User) error {
+	review, err := s.findProductReview(productId, reviewId)
+	if err != nil {
+		return err
+	}
+	if review.UserId == user.ID {
+		return ErrOwnReviewVote
+	}
+	return s.Repo.AddHelpfulVote(review.ID, user.ID)
+}
+
+// ReplyToReview adds the seller's single public reply to a review of one of their products
+func (s ReviewService) ReplyToReview(productId int, reviewId int, input dto.ReviewReplyRequest, user domain.User) (*domain.Review, error) {
+	product, err := s.CRepo.FindProductByID(productId)
+	if err != nil {
+		return nil, productLookupError(err)
+	}
+	if product.UserId != user.ID {
+		return nil, ErrProductNotOwned
+	}
+	review, err := s.findProductReview(productId, reviewId)
+	if err != nil {
+		return nil, err
+	}
+	if len(review.SellerReply) > 0 {
+		return nil, ErrAlreadyReplied
+	}
+	reply := strings.TrimSpace(input.Reply)
+	if len(reply) == 0 {
+		return nil, ErrInvalidReply
+	}
+
+	now := time.Now()
+	review.SellerReply = reply
+	review.SellerRepliedAt = &now
+	err = s.Repo.UpdateReview(review)
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (s ReviewService) findProductReview(productId int, reviewId int) (*domain.Review, error) {
+	review, err := s.Repo.FindReviewByID(uint(reviewId))
+	if err != nil || review.ProductId != uint(productId) {
+		return nil, ErrReviewNotFound
+	}
+	return review, nil
+}
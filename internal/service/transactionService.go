@@ -1,38 +1,318 @@
 package service
 
 import (
+	"ecommerce-app/config"
 	"ecommerce-app/internal/domain"
 	"ecommerce-app/internal/dto"
 	"ecommerce-app/internal/helper"
 	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/money"
+	"ecommerce-app/pkg/payment"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrPaymentNotFound   = errors.New("payment does not exist")
+	ErrOrderNotPayable   = errors.New("order is not awaiting payment")
+	ErrPaymentNotPending = errors.New("payment is not waiting for authentication")
+	ErrPaymentMethod     = errors.New("please provide a payment method")
+	ErrPaymentDeclined   = errors.New("payment was declined")
 )
 
+// payment statuses that can still turn into a charge
+var openPaymentStatuses = []string{
+	payment.StatusRequiresConfirmation,
+	payment.StatusRequiresAction,
+	payment.StatusRequiresCapture,
+}
+
 type TransactionService struct {
-	Repo repository.TransactionRepository
-	Auth helper.Auth
+	Repo     repository.TransactionRepository
+	ORepo    repository.OrderRepository
+	Provider payment.PaymentProvider
+	Auth     helper.Auth
+	Config   config.AppConfig
 }
 
-func NewTransactionService(r repository.TransactionRepository, auth helper.Auth) *TransactionService {
+func NewTransactionService(r repository.TransactionRepository, o repository.OrderRepository,
+	provider payment.PaymentProvider, auth helper.Auth, config config.AppConfig) *TransactionService {
 	return &TransactionService{
-	Repo: r,
-	Auth: auth,
+		Repo:     r,
+		ORepo:    o,
+		Provider: provider,
+		Auth:     auth,
+		Config:   config,
+	}
+}
+
+// Checkout charges the buyer's payment method for an order awaiting payment. Each attempt is a new
+// payment intent, earlier attempts still open are cancelled. A card that needs authentication leaves
+// the payment in requires_action until ConfirmPayment, a successful payment marks the order paid.
+// An order its discounts fully cover is marked paid without going through the provider.
+func (s TransactionService) Checkout(orderId uint, input dto.MakePaymentRequest, buyer domain.User) (*domain.Payment, error) {
+	method := strings.TrimSpace(input.PaymentMethod)
+	if len(method) == 0 {
+		return nil, ErrPaymentMethod
+	}
+	order, err := s.ORepo.FindOrderByID(orderId)
+	if err != nil || order.UserId != buyer.ID {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != domain.OrderStatusPendingPayment {
+		return nil, ErrOrderNotPayable
+	}
+	s.CancelOpenPayments(order.ID)
+	if order.Amount.IsZero() {
+		return s.settleFreeOrder(order, buyer)
+	}
+
+	intent, err := s.Provider.CreateIntent(payment.IntentParams{
+		Amount:        order.Amount.Amount,
+		Currency:      order.Amount.Currency, // the buyer's currency at checkout
+		CaptureMethod: payment.CaptureAutomatic,
+		CustomerId:    fmt.Sprint(buyer.ID),
+		Reference:     fmt.Sprint(order.OrderRefNumber),
+	})
+	if err != nil {
+		log.Printf("payment_err: %v", err)
+		return nil, errors.New("could not start payment")
+	}
+	p := &domain.Payment{
+		OrderId:       order.ID,
+		UserId:        buyer.ID,
+		Provider:      s.Provider.Name(),
+		CaptureMethod: intent.CaptureMethod,
+		Amount:        money.New(intent.Amount, strings.ToUpper(intent.Currency)),
+		CustomerId:    fmt.Sprint(buyer.ID),
+		PaymentId:     intent.ID,
+		Status:        intent.Status,
+	}
+	if err = s.Repo.CreatePayment(p); err != nil {
+		return nil, err
+	}
+
+	intent, err = s.Provider.Confirm(intent.ID, payment.ConfirmParams{PaymentMethod: method})
+	if err != nil {
+		log.Printf("payment_err: %v", err)
+		return nil, errors.New("could not confirm payment")
+	}
+	return s.applyIntent(p, intent)
+}
+
+// settleFreeOrder marks an order with nothing to pay as paid and records a succeeded payment of zero
+// for it, no intent is created as providers refuse to charge nothing
+func (s TransactionService) settleFreeOrder(order *domain.Order, buyer domain.User) (*domain.Payment, error) {
+	_, err := s.ORepo.TransitionOrder(order.ID, domain.OrderStatusPaid, 0, "nothing to pay after discounts")
+	if errors.Is(err, ErrInvalidOrderTransition) {
+		return nil, ErrOrderNotPayable
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &domain.Payment{
+		OrderId:    order.ID,
+		UserId:     buyer.ID,
+		Amount:     order.Amount,
+		CustomerId: fmt.Sprint(buyer.ID),
+		Status:     payment.StatusSucceeded,
+	}
+	if err = s.Repo.CreatePayment(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ConfirmPayment completes a payment after the buyer authenticated the card
+func (s TransactionService) ConfirmPayment(id uint, buyer domain.User) (*domain.Payment, error) {
+	p, err := s.buyerPayment(id, buyer)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != payment.StatusRequiresAction {
+		return nil, ErrPaymentNotPending
+	}
+	intent, err := s.Provider.Confirm(p.PaymentId, payment.ConfirmParams{Authenticated: true})
+	if err != nil {
+		log.Printf("payment_err: %v", err)
+		return nil, errors.New("could not confirm payment")
 	}
+	return s.applyIntent(p, intent)
 }
 
-func (s TransactionService) GetOrders(u domain.User) ([]domain.OrderItem, error) {
-	orders, err := s.Repo.FindOrders(u.ID)
+// GetPayment returns the buyer's payment refreshed from the provider
+func (s TransactionService) GetPayment(id uint, buyer domain.User) (*domain.Payment, error) {
+	p, err := s.buyerPayment(id, buyer)
 	if err != nil {
 		return nil, err
 	}
-	return orders, nil
+	intent, err := s.Provider.FetchStatus(p.PaymentId)
+	if err != nil {
+		// payments of a previous provider can no longer be refreshed
+		return p, nil
+	}
+	p, err = s.applyIntent(p, intent)
+	if errors.Is(err, ErrPaymentDeclined) {
+		return p, nil
+	}
+	return p, err
 }
 
+// CancelOpenPayments cancels the order's payment attempts that could still be charged, failures are only logged
+func (s TransactionService) CancelOpenPayments(orderId uint) {
+	payments, err := s.Repo.FindOrderPayments(orderId)
+	if err != nil {
+		return
+	}
+	for i := range payments {
+		p := &payments[i]
+		if !containsStatus(openPaymentStatuses, p.Status) {
+			continue
+		}
+		intent, err := s.Provider.Cancel(p.PaymentId)
+		if err != nil {
+			log.Printf("payment_err: could not cancel payment %d: %v", p.ID, err)
+			continue
+		}
+		if _, err = s.applyIntent(p, intent); err != nil {
+			log.Printf("payment_err: %v", err)
+		}
+	}
+}
+
+// RefundPayment refunds the amount through the provider against the payment intent of the order
+func (s TransactionService) RefundPayment(refund *domain.Refund) error {
+	if len(refund.PaymentId) == 0 {
+		refund.Status = domain.RefundStatusFailed
+		return errors.New("order has no payment to refund")
+	}
+	result, err := s.Provider.Refund(refund.PaymentId, refund.Amount.Amount, refund.Reason)
+	if err != nil {
+		refund.Status = domain.RefundStatusFailed
+		return err
+	}
+	refund.ProviderRef = result.ID
+	refund.Status = domain.RefundStatusPending
+	if result.Status == payment.StatusSucceeded {
+		refund.Status = domain.RefundStatusSucceeded
+	}
+	return nil
+}
+
+// applyIntent stores the provider's view of the payment. The order is marked paid when the payment
+// first succeeds. A failed payment releases the order and its stock, and returns ErrPaymentDeclined
+// with the provider's reason. A payment that succeeds for an order no longer awaiting payment,
+// e.g. cancelled meanwhile or paid by another attempt, is refunded. The payment stays locked from
+// reading its status until the order is marked paid, so concurrent updates apply it once.
+func (s TransactionService) applyIntent(p *domain.Payment, intent *payment.Intent) (*domain.Payment, error) {
+	failed := false
+	response, _ := json.Marshal(intent)
+	p, unneeded, err := s.Repo.ApplyPayment(p.ID, func(p *domain.Payment) bool {
+		succeeded := p.Status != payment.StatusSucceeded && intent.Status == payment.StatusSucceeded
+		failed = p.Status != payment.StatusFailed && intent.Status == payment.StatusFailed
+		p.Status = intent.Status
+		p.NextAction = intent.NextAction
+		p.ChargeId = intent.ChargeId
+		p.Response = string(response)
+		return succeeded
+	}, "payment "+p.PaymentId+" succeeded")
+	if err != nil {
+		return nil, err
+	}
+
+	if unneeded {
+		s.refundUnneededPayment(p)
+	}
+	if failed {
+		s.releaseUnpaidOrder(p.OrderId, "payment "+p.PaymentId+" failed")
+	}
+	if p.Status == payment.StatusFailed {
+		return p, errors.Wrap(ErrPaymentDeclined, intent.FailureMessage)
+	}
+	return p, nil
+}
+
+// releaseUnpaidOrder cancels an order still awaiting payment so its items go back in stock,
+// the buyer checks out again to retry. Failures are only logged.
+func (s TransactionService) releaseUnpaidOrder(orderId uint, reason string) {
+	released, err := s.ORepo.ReleaseUnpaidOrder(orderId, reason)
+	if err != nil {
+		log.Printf("payment_err: could not release order %d: %v", orderId, err)
+		return
+	}
+	if released {
+		s.CancelOpenPayments(orderId)
+	}
+}
+
+// ExpireUnpaidOrders releases the orders that have been awaiting payment for longer than maxAge,
+// e.g. abandoned checkouts or cards never authenticated
+func (s TransactionService) ExpireUnpaidOrders(maxAge time.Duration) error {
+	ids, err := s.ORepo.FindUnpaidOrderIds(time.Now().Add(-maxAge))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.releaseUnpaidOrder(id, "payment not received in time")
+	}
+	return nil
+}
+
+// WatchUnpaidOrders runs ExpireUnpaidOrders every interval for the lifetime of the process
+func (s TransactionService) WatchUnpaidOrders(interval time.Duration, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.ExpireUnpaidOrders(maxAge); err != nil {
+			log.Printf("error expiring unpaid orders %v", err)
+		}
+	}
+}
+
+// refundUnneededPayment gives back a payment the order did not need, failures are only logged
+func (s TransactionService) refundUnneededPayment(p *domain.Payment) {
+	refund := &domain.Refund{
+		OrderId:   p.OrderId,
+		PaymentId: p.PaymentId,
+		Amount:    p.Amount,
+		Reason:    "order was no longer awaiting payment",
+	}
+	if err := s.RefundPayment(refund); err != nil {
+		log.Printf("payment_err: could not refund payment %d: %v", p.ID, err)
+	}
+	if err := s.Repo.CreateRefund(refund); err != nil {
+		log.Printf("payment_err: %v", err)
+	}
+}
+
+func (s TransactionService) buyerPayment(id uint, buyer domain.User) (*domain.Payment, error) {
+	p, err := s.Repo.FindPaymentById(id)
+	if err != nil || p.UserId != buyer.ID {
+		return nil, ErrPaymentNotFound
+	}
+	return p, nil
+}
+
+// GetOrders lists the order items sold by the seller, newest first
+func (s TransactionService) GetOrders(u domain.User, input dto.SellerOrderQuery) ([]dto.SellerOrderDetails, dto.PageMeta, error) {
+	page, limit := pageBounds(input.PageQuery)
+	orders, total, err := s.Repo.FindOrders(u.ID, input.Status, page, limit)
+	if err != nil {
+		return nil, dto.PageMeta{}, err
+	}
+	return orders, dto.PageMeta{Page: page, Limit: limit, Total: total}, nil
+}
+
+// GetOrderDetails returns one order item sold by the seller with the buyer's contact and shipping address
 func (s TransactionService) GetOrderDetails(u domain.User, id uint) (dto.SellerOrderDetails, error) {
 	order, err := s.Repo.FindOrderById(u.ID, id)
 	if err != nil {
-		return dto.SellerOrderDetails{}, err
+		return dto.SellerOrderDetails{}, ErrOrderNotFound
 	}
 	return order, nil
 }
-
-
@@ -6,6 +6,7 @@ import (
 	"ecommerce-app/internal/dto"
 	"ecommerce-app/internal/helper"
 	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/money"
 	"ecommerce-app/pkg/notification"
 	"errors"
 	"fmt"
@@ -16,8 +17,10 @@ import (
 type UserService struct {
 	Repo   repository.UserRepository
 	CRepo  repository.CatalogRepository
+	PRepo  repository.PromotionRepository
 	Auth   helper.Auth
 	Config config.AppConfig
+	Rates  *money.Rates
 }
 
 func (s UserService) findUserByEmail(email string) (*domain.User, error) {
@@ -30,7 +33,8 @@ func (s UserService) findUserByEmail(email string) (*domain.User, error) {
 	return &user, nil
 }
 
-func (s UserService) Signup(input dto.UserSignUp) (string, error) {
+// Signup creates the account and moves the visitor's guest cart, if any, into it
+func (s UserService) Signup(input dto.UserSignUp, cartToken string) (string, error) {
 
 	hPassword, err := s.Auth.CreateHashedPassword(input.Password)
 	if err != nil {
@@ -42,12 +46,17 @@ func (s UserService) Signup(input dto.UserSignUp) (string, error) {
 		Password: hPassword,
 		Phone:    input.Phone,
 	})
+	if err != nil {
+		return "", err
+	}
+	s.mergeGuestCart(cartToken, user.ID)
 
 	// generate token
 	return s.Auth.GenerateToken(user.ID, user.Email, user.UserType)
 }
 
-func (s UserService) Login(email string, password string) (string, error) {
+// Login checks the credentials and moves the visitor's guest cart, if any, into the user's cart
+func (s UserService) Login(email string, password string, cartToken string) (string, error) {
 	user, err := s.findUserByEmail(email)
 	if err != nil {
 		return "", errors.New("user does not exist with the provided email id")
@@ -56,6 +65,8 @@ func (s UserService) Login(email string, password string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	s.mergeGuestCart(cartToken, user.ID)
+
 	// generate token
 
 	return s.Auth.GenerateToken(user.ID, user.Email, user.UserType)
@@ -204,6 +215,11 @@ func (s UserService) UpdateProfile(id uint, input dto.ProfileInput) error {
 	if input.Email != "" {
 		user.Email = input.Email
 	}
+	if input.Currency != "" {
+		if user.Currency, err = supportedCurrency(s.Rates, input.Currency); err != nil {
+			return err
+		}
+	}
 	// Update the user details
 	_, err = s.Repo.UpdateUser(id, user)
 
@@ -232,6 +248,13 @@ func (s UserService) BecomeSeller(id uint, input dto.SellerInput) (string, error
 	if user.UserType == domain.SELLER {
 		return "", errors.New("you have already joined the seller program")
 	}
+	var currency string
+	if input.Currency != "" {
+		var err error
+		if currency, err = supportedCurrency(s.Rates, input.Currency); err != nil {
+			return "", err
+		}
+	}
 
 	// Update the user
 	seller, err := s.Repo.UpdateUser(id, domain.User{
@@ -239,6 +262,7 @@ func (s UserService) BecomeSeller(id uint, input dto.SellerInput) (string, error
 		LastName:  input.LastName,
 		Phone:     input.PhoneNumber,
 		UserType:  domain.SELLER,
+		Currency:  currency,
 	})
 	if err != nil {
 		return "", err
@@ -267,106 +291,130 @@ func (s UserService) FindCart(id uint) ([]domain.Cart, error) {
 	return cartItems, nil
 }
 
+// CreateCart adds the product to the user's cart, adding to the quantity when it is already there
 func (s UserService) CreateCart(input dto.CreateCartRequest, u domain.User) ([]domain.Cart, error) {
-	
-	cart, _ := s.Repo.FindCartItem(u.ID, input.ProductId)
+	return s.addCartItem(s.userCart(u.ID), input, u.ID)
+}
 
-	if cart.ID > 0 {
-		if input.ProductId == 0 {
-			return nil, errors.New("please provide a valid product id")
-		}
-		// => delete the cart item
-		if input.Qty < 1 {
-			err := s.Repo.DeleteCartById(cart.ID)
-			if err != nil {
-				log.Printf("Error on deleting cart item %v", err)
-				return nil, errors.New("error on deleting cart item")
-			}
-		} else {
-			// update the cart item
-			cart.Qty = input.Qty
-			err := s.Repo.UpdateCart(cart)
-			if err != nil {
-				return nil, errors.New("error on updating cart item")
-			}
-		}
+// UpdateCartItem sets the quantity of a product already in the user's cart
+func (s UserService) UpdateCartItem(productId uint, input dto.UpdateCartRequest, u domain.User) ([]domain.Cart, error) {
+	return s.updateCartItem(s.userCart(u.ID), productId, input, u.ID)
+}
 
-	} else {
-		// Check if product exists
-		product, err := s.CRepo.FindProductByID(int(input.ProductId))
-		if err != nil {
-			return nil, errors.New("product not found to create cart item")
-		}
-		// create the cart item
-		err = s.Repo.CreateCart(domain.Cart{
-			ProductId: input.ProductId,
-			UserId:    u.ID,
-			Name:      product.Name,
-			ImageUrl:  product.ImageUrl,
-			Qty:       input.Qty,
-			Price:     product.Price,
-			SellerId:  product.UserId,
-		})
+// RemoveCartItem removes the product from the user's cart, every variant of it when variantId is 0
+func (s UserService) RemoveCartItem(productId uint, variantId uint, u domain.User) ([]domain.Cart, error) {
+	return removeCartItem(s.userCart(u.ID), productId, variantId)
+}
+
+func (s UserService) ClearCart(u domain.User) error {
+	return s.userCart(u.ID).Clear()
+}
 
-		if err != nil {
-			return nil, errors.New("error on creating cart item")
+// selectVariant picks the requested variant of the product, defaulting to its only variant
+func selectVariant(product *domain.Product, variantId uint) (*domain.ProductVariant, error) {
+	if variantId == 0 {
+		if len(product.Variants) == 1 {
+			return &product.Variants[0], nil
+		}
+		return nil, ErrVariantRequired
+	}
+	for i := range product.Variants {
+		if product.Variants[i].ID == variantId {
+			return &product.Variants[i], nil
 		}
 	}
-	return s.Repo.FindCartItems(u.ID)
+	return nil, ErrVariantNotFound
+}
+
+// variantName is the product name followed by the variant title, e.g. "T-Shirt (M / Red)"
+func variantName(product *domain.Product, variant *domain.ProductVariant) string {
+	if len(variant.Title) == 0 {
+		return product.Name
+	}
+	return fmt.Sprintf("%s (%s)", product.Name, variant.Title)
 }
 
-func (s UserService) CreateOrder(u domain.User) (int, error) {
+// CreateOrder turns the cart into an order at current prices, applying the coupon on the cart.
+// It returns ErrCartChanged until the buyer acknowledged repriced or removed lines. Stock is checked and
+// decremented atomically, a *repository.InsufficientStockError lists the lines that are short.
+// The order awaits payment, see TransactionService.Checkout.
+func (s UserService) CreateOrder(u domain.User) (*domain.Order, error) {
 	//find cart items for the user
 	cartItems, err := s.Repo.FindCartItems(u.ID)
 	if err != nil {
-		return 0, errors.New("error on finding cart items")
+		return nil, errors.New("error on finding cart items")
 	}
 	if len(cartItems) == 0 {
-		return 0, errors.New("cart is empty, cannot create order")
+		return nil, errors.New("cart is empty, cannot create order")
 	}
 
-	// find success payment reference status
-	paymentId := "PAY1234567890"
+	summary, promotion, err := s.priceCart(u, cartItems)
+	if err != nil {
+		return nil, err
+	}
+	if summary.NeedsAcknowledgement {
+		return nil, ErrCartChanged
+	}
 
-	txnId := "TXN1234567890"
 	orderRef, _ := helper.RandomNumbers(8)
 
 	//create order with generated OrderRef
-	var amount float64
 	var orderItems []domain.OrderItem
-	for _, item := range cartItems {	
-		amount += item.Price * float64(item.Qty)
-		orderItems = append(orderItems, domain.OrderItem{	 
-			ProductId: 	item.ProductId,
-			Qty: 			 	item.Qty,
-			Price: 			item.Price,
-			Name: 			item.Name,
-			ImageUrl:		item.ImageUrl,
-			SellerId: 	item.SellerId,
+	for _, line := range summary.Items {
+		var sku string
+		variant, err := s.CRepo.FindVariantByID(line.VariantId)
+		if err == nil {
+			sku = variant.Sku
+		}
+		orderItems = append(orderItems, domain.OrderItem{
+			ProductId:   line.ProductId,
+			VariantId:   line.VariantId,
+			Sku:         sku,
+			Qty:         line.Qty,
+			Price:       line.Price,
+			Discount:    line.Discount,
+			SellerPrice: line.SellerPrice,
+			Name:        line.Name,
+			ImageUrl:    line.ImageUrl,
+			SellerId:    line.SellerId,
 		})
 	}
 
 	order := domain.Order{
-		UserId: 			 u.ID,
-		PaymentId:     paymentId,
-		TransactionId: txnId,
-	  OrderRefNumber: uint(orderRef),	
-		Amount: 				amount,
-		Items: 					orderItems,
-	}
-	err = s.Repo.CreateOrder(order)
+		UserId:           u.ID,
+		OrderRefNumber:   uint(orderRef),
+		Amount:           summary.Total,
+		Subtotal:         summary.Subtotal,
+		Discount:         summary.Discount,
+		Shipping:         summary.Shipping,
+		ShippingDiscount: summary.ShippingDiscount,
+		Tax:              summary.Tax,
+		Items:            orderItems,
+	}
+	for _, seller := range summary.Sellers {
+		order.Groups = append(order.Groups, domain.FulfilmentGroup{
+			SellerId:         seller.SellerId,
+			Subtotal:         seller.Subtotal,
+			Discount:         seller.Discount,
+			Shipping:         seller.Shipping,
+			ShippingDiscount: seller.ShippingDiscount,
+			Tax:              seller.Tax,
+			Amount:           seller.Total,
+		})
+	}
+	if promotion != nil {
+		order.PromotionId = promotion.ID
+		order.CouponCode = promotion.Code
+	}
+	// stock is decremented and the cart emptied in the same transaction
+	err = s.Repo.PlaceOrder(&order)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// Send email to user with order details
 
-	// remove cart items from the cart once the order is created
-	err = s.Repo.DeleteCartItems(u.ID)
-	log.Printf("Deleting cart items Error: %v", err)
-
-	//return order number
-	return orderRef, nil
+	return &order, nil
 }
 
 func (s UserService) GetOrders(u domain.User) ([]domain.Order, error) {
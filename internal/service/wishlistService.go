@@ -0,0 +1,257 @@
+package service
+
+import (
+	"ecommerce-app/config"
+	"ecommerce-app/internal/domain"
+	"ecommerce-app/internal/dto"
+	"ecommerce-app/internal/helper"
+	"ecommerce-app/internal/repository"
+	"ecommerce-app/pkg/notification"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrWishlistNotFound     = errors.New("wishlist does not exist")
+	ErrWishlistItemNotFound = errors.New("product is not in the wishlist")
+	ErrInvalidWishlist      = errors.New("wishlist name is required")
+)
+
+type WishlistService struct {
+	Repo    repository.WishlistRepository
+	CRepo   repository.CatalogRepository
+	UserSvc UserService
+	Auth    helper.Auth
+	Config  config.AppConfig
+}
+
+func (s WishlistService) GetWishlists(user domain.User) ([]*domain.Wishlist, error) {
+	return s.Repo.FindWishlists(user.ID)
+}
+
+func (s WishlistService) GetWishlist(id int, user domain.User) (*domain.Wishlist, error) {
+	wishlist, err := s.Repo.FindWishlistByID(uint(id))
+	if err != nil || wishlist.UserId != user.ID {
+		return nil, ErrWishlistNotFound
+	}
+	return wishlist, nil
+}
+
+func (s WishlistService) CreateWishlist(input dto.CreateWishlistRequest, user domain.User) (*domain.Wishlist, error) {
+	name := strings.TrimSpace(input.Name)
+	if len(name) == 0 {
+		return nil, ErrInvalidWishlist
+	}
+	wishlist := &domain.Wishlist{
+		UserId: user.ID,
+		Name:   name,
+		Items:  []domain.WishlistItem{},
+	}
+	err := s.Repo.CreateWishlist(wishlist)
+	if err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+func (s WishlistService) RenameWishlist(id int, input dto.CreateWishlistRequest, user domain.User) (*domain.Wishlist, error) {
+	wishlist, err := s.GetWishlist(id, user)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(input.Name)
+	if len(name) == 0 {
+		return nil, ErrInvalidWishlist
+	}
+	wishlist.Name = name
+	err = s.Repo.UpdateWishlist(wishlist)
+	if err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+func (s WishlistService) DeleteWishlist(id int, user domain.User) error {
+	wishlist, err := s.GetWishlist(id, user)
+	if err != nil {
+		return err
+	}
+	return s.Repo.DeleteWishlist(wishlist)
+}
+
+// AddItem saves the product, remembering its current price and stock as the baseline for alerts
+func (s WishlistService) AddItem(id int, input dto.WishlistItemRequest, user domain.User) (*domain.Wishlist, error) {
+	wishlist, err := s.GetWishlist(id, user)
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.CRepo.FindProductByID(int(input.ProductId))
+	if err != nil {
+		return nil, productLookupError(err)
+	}
+
+	item := &domain.WishlistItem{
+		WishlistId:    wishlist.ID,
+		ProductId:     product.ID,
+		LastSeenPrice: product.Price,
+		LastSeenStock: product.Stock,
+	}
+	if input.VariantId > 0 {
+		variant, err := selectVariant(product, input.VariantId)
+		if err != nil {
+			return nil, ErrVariantNotFound
+		}
+		item.VariantId = variant.ID
+		item.LastSeenPrice = variant.PriceFor(product)
+		item.LastSeenStock = variant.Stock
+	}
+
+	err = s.Repo.AddWishlistItem(item)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.FindWishlistByID(wishlist.ID)
+}
+
+func (s WishlistService) RemoveItem(id int, itemId int, user domain.User) error {
+	wishlist, err := s.GetWishlist(id, user)
+	if err != nil {
+		return err
+	}
+	item, err := findWishlistItem(wishlist, uint(itemId))
+	if err != nil {
+		return err
+	}
+	return s.Repo.DeleteWishlistItem(item.ID)
+}
+
+// MoveItemToCart adds the item to the buyer's cart and removes it from the wishlist
+func (s WishlistService) MoveItemToCart(id int, itemId int, input dto.MoveToCartRequest, user domain.User) ([]domain.Cart, error) {
+	wishlist, err := s.GetWishlist(id, user)
+	if err != nil {
+		return nil, err
+	}
+	item, err := findWishlistItem(wishlist, uint(itemId))
+	if err != nil {
+		return nil, err
+	}
+
+	req := dto.CreateCartRequest{
+		ProductId: item.ProductId,
+		VariantId: item.VariantId,
+		Qty:       input.Qty,
+	}
+	if req.VariantId == 0 {
+		req.VariantId = input.VariantId
+	}
+	if req.Qty < 1 {
+		req.Qty = 1
+	}
+
+	cart, err := s.UserSvc.CreateCart(req, user)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Repo.DeleteWishlistItem(item.ID)
+	if err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// ShareWishlist makes the wishlist readable by anyone holding its share link
+func (s WishlistService) ShareWishlist(id int, user domain.User) (*dto.WishlistShareResponse, error) {
+	wishlist, err := s.GetWishlist(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if wishlist.ShareToken == nil {
+		token, err := helper.RandomToken(16)
+		if err != nil {
+			return nil, err
+		}
+		wishlist.ShareToken = &token
+		if err = s.Repo.UpdateWishlist(wishlist); err != nil {
+			return nil, err
+		}
+	}
+	return &dto.WishlistShareResponse{
+		ShareToken: *wishlist.ShareToken,
+		ShareUrl:   "/wishlists/shared/" + *wishlist.ShareToken,
+	}, nil
+}
+
+// UnshareWishlist revokes the share link, a later share creates a new one
+func (s WishlistService) UnshareWishlist(id int, user domain.User) error {
+	wishlist, err := s.GetWishlist(id, user)
+	if err != nil {
+		return err
+	}
+	wishlist.ShareToken = nil
+	return s.Repo.UpdateWishlist(wishlist)
+}
+
+func (s WishlistService) GetSharedWishlist(token string) (*domain.Wishlist, error) {
+	wishlist, err := s.Repo.FindWishlistByShareToken(token)
+	if err != nil {
+		return nil, ErrWishlistNotFound
+	}
+	// the owner is not part of the public view
+	wishlist.UserId = 0
+	return wishlist, nil
+}
+
+// NotifyWatchers sends an SMS for every wishlisted product whose price dropped or that came back in stock
+// since its owner was last told, then records the current values as seen.
+func (s WishlistService) NotifyWatchers() error {
+	changes, err := s.Repo.FindChangedWishlistItems()
+	if err != nil {
+		return err
+	}
+	notificationClient := notification.NewNotificationClient(s.Config)
+
+	for _, c := range changes {
+		var msg string
+		switch {
+		case c.Stock > 0 && c.LastSeenStock == 0:
+			msg = fmt.Sprintf("%s from your wishlist is back in stock", c.ProductName)
+		// a product repriced in another currency only moves the baseline
+		case c.Price.Currency == c.LastSeenPrice.Currency && c.Price.Less(c.LastSeenPrice):
+			msg = fmt.Sprintf("%s from your wishlist dropped in price from %s to %s", c.ProductName, c.LastSeenPrice, c.Price)
+		}
+		if len(msg) > 0 && len(c.Phone) > 0 {
+			if err = notificationClient.SendSMS(c.Phone, msg); err != nil {
+				log.Printf("error sending wishlist alert %v", err)
+				continue
+			}
+		}
+		if err = s.Repo.MarkWishlistItemSeen(c.ItemId, c.Price, c.Stock); err != nil {
+			log.Printf("error updating wishlist item %v", err)
+		}
+	}
+	return nil
+}
+
+// WatchWishlists runs NotifyWatchers every interval for the lifetime of the process
+func (s WishlistService) WatchWishlists(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.NotifyWatchers(); err != nil {
+			log.Printf("error checking wishlists %v", err)
+		}
+	}
+}
+
+func findWishlistItem(wishlist *domain.Wishlist, itemId uint) (*domain.WishlistItem, error) {
+	for i := range wishlist.Items {
+		if wishlist.Items[i].ID == itemId {
+			return &wishlist.Items[i], nil
+		}
+	}
+	return nil, ErrWishlistItemNotFound
+}
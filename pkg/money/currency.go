@@ -0,0 +1,82 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+var ErrUnknownCurrency = errors.New("currency is not supported")
+
+// Currency is how amounts in an ISO 4217 currency are stored and rounded
+type Currency struct {
+	Code   string
+	Digits int // minor unit digits, 2 for cents and 0 for currencies without a minor unit
+	// Increment is the smallest amount, in minor units, a rounded result may be a multiple of.
+	// It is 5 for CHF, where prices are rounded to 5 centimes.
+	Increment int64
+}
+
+var currencies = map[string]Currency{
+	"AUD": {Code: "AUD", Digits: 2, Increment: 1},
+	"BHD": {Code: "BHD", Digits: 3, Increment: 1},
+	"CAD": {Code: "CAD", Digits: 2, Increment: 1},
+	"CHF": {Code: "CHF", Digits: 2, Increment: 5},
+	"CNY": {Code: "CNY", Digits: 2, Increment: 1},
+	"DKK": {Code: "DKK", Digits: 2, Increment: 1},
+	"EUR": {Code: "EUR", Digits: 2, Increment: 1},
+	"GBP": {Code: "GBP", Digits: 2, Increment: 1},
+	"HKD": {Code: "HKD", Digits: 2, Increment: 1},
+	"INR": {Code: "INR", Digits: 2, Increment: 1},
+	"JPY": {Code: "JPY", Digits: 0, Increment: 1},
+	"KRW": {Code: "KRW", Digits: 0, Increment: 1},
+	"KWD": {Code: "KWD", Digits: 3, Increment: 1},
+	"MXN": {Code: "MXN", Digits: 2, Increment: 1},
+	"NGN": {Code: "NGN", Digits: 2, Increment: 1},
+	"NOK": {Code: "NOK", Digits: 2, Increment: 1},
+	"NZD": {Code: "NZD", Digits: 2, Increment: 1},
+	"PLN": {Code: "PLN", Digits: 2, Increment: 1},
+	"SEK": {Code: "SEK", Digits: 2, Increment: 1},
+	"SGD": {Code: "SGD", Digits: 2, Increment: 1},
+	"USD": {Code: "USD", Digits: 2, Increment: 1},
+	"ZAR": {Code: "ZAR", Digits: 2, Increment: 1},
+}
+
+// Lookup returns the rounding rules of the currency, codes are case insensitive
+func Lookup(code string) (Currency, error) {
+	c, ok := currencies[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Currency{}, ErrUnknownCurrency
+	}
+	return c, nil
+}
+
+// Normalize returns the upper case code of a supported currency
+func Normalize(code string) (string, error) {
+	c, err := Lookup(code)
+	return c.Code, err
+}
+
+// scale is the number of minor units in one major unit, 100 for cents
+func (c Currency) scale() *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Digits)), nil)
+}
+
+// Round rounds an exact amount of minor units to the nearest increment of the currency, halves away from zero
+func (c Currency) Round(r *big.Rat) int64 {
+	inc := c.Increment
+	if inc < 1 {
+		inc = 1
+	}
+	steps := new(big.Rat).Quo(r, new(big.Rat).SetInt64(inc))
+	q, m := new(big.Int).QuoRem(steps.Num(), steps.Denom(), new(big.Int))
+	// |remainder| * 2 >= denominator means the fraction is at least a half
+	if m.Abs(m).Lsh(m, 1).Cmp(steps.Denom()) >= 0 {
+		if steps.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64() * inc
+}
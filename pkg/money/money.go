@@ -0,0 +1,170 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidAmount = errors.New("amount is not a valid price")
+
+// Money is an exact amount in integer minor units of an ISO 4217 currency, e.g. 1999 USD is $19.99.
+// Embed it in gorm models with `gorm:"embedded;embeddedPrefix:price_"` to store price_minor and price_currency.
+// The zero value has no currency and stands for "not set", it encodes to JSON null.
+type Money struct {
+	Amount   int64  `json:"amount" gorm:"column:minor;default:0"`
+	Currency string `json:"currency" gorm:"column:currency;size:3"`
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Zero is no money in the currency, the starting point of totals
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// Parse reads a decimal amount such as "19.99" in the currency, more decimals than the currency has are rejected
+func Parse(s string, currency string) (Money, error) {
+	c, err := Lookup(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(whole) == 0 || len(frac) > c.Digits || strings.ContainsAny(whole, "+") {
+		return Money{}, ErrInvalidAmount
+	}
+	frac += strings.Repeat("0", c.Digits-len(frac))
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+	return Money{Amount: amount, Currency: c.Code}, nil
+}
+
+// IsSet reports whether the amount has a currency, unset amounts are for optional prices
+func (m Money) IsSet() bool {
+	return len(m.Currency) > 0
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// same checks that both amounts can be added up, a zero value without currency takes the other's currency
+func (m Money) same(o Money) string {
+	switch {
+	case m.Currency == o.Currency || len(o.Currency) == 0:
+		return m.Currency
+	case len(m.Currency) == 0:
+		return o.Currency
+	}
+	panic(fmt.Sprintf("money: mixing %s and %s, convert one of them first", m.Currency, o.Currency))
+}
+
+func (m Money) Add(o Money) Money {
+	return Money{Amount: m.Amount + o.Amount, Currency: m.same(o)}
+}
+
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: m.same(o)}
+}
+
+// Mul is the amount for n units, e.g. a line total
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or more than o
+func (m Money) Cmp(o Money) int {
+	m.same(o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) Less(o Money) bool {
+	return m.Cmp(o) < 0
+}
+
+func Min(a Money, b Money) Money {
+	if b.Less(a) {
+		return b
+	}
+	return a
+}
+
+func Max(a Money, b Money) Money {
+	if a.Less(b) {
+		return b
+	}
+	return a
+}
+
+// Share is part/whole of the amount, rounded by the currency's rules. A zero whole gives nothing.
+func (m Money) Share(part int64, whole int64) Money {
+	if whole == 0 {
+		return Zero(m.Currency)
+	}
+	r := new(big.Rat).SetFrac(big.NewInt(m.Amount), big.NewInt(whole))
+	r.Mul(r, new(big.Rat).SetInt64(part))
+	return m.round(r)
+}
+
+// Percent is pct percent of the amount, rounded by the currency's rules. pct is read as the decimal
+// it prints as, so 7.5 is exactly 7.5%.
+func (m Money) Percent(pct float64) Money {
+	rate, ok := new(big.Rat).SetString(strconv.FormatFloat(pct, 'f', -1, 64))
+	if !ok {
+		return Zero(m.Currency)
+	}
+	r := new(big.Rat).SetInt64(m.Amount)
+	r.Mul(r, rate)
+	r.Quo(r, big.NewRat(100, 1))
+	return m.round(r)
+}
+
+func (m Money) round(r *big.Rat) Money {
+	c, err := Lookup(m.Currency)
+	if err != nil {
+		c = Currency{Code: m.Currency, Increment: 1}
+	}
+	return Money{Amount: c.Round(r), Currency: m.Currency}
+}
+
+// Decimal formats the amount in major units, e.g. "19.99" or "1500" for JPY
+func (m Money) Decimal() string {
+	c, err := Lookup(m.Currency)
+	if err != nil || c.Digits == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+	r := new(big.Rat).SetFrac(big.NewInt(m.Amount), c.scale())
+	return r.FloatString(c.Digits)
+}
+
+// String formats the amount for people, e.g. "19.99 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// MarshalJSON writes {"amount": 1999, "currency": "USD"}, or null when the amount is not set
+func (m Money) MarshalJSON() ([]byte, error) {
+	if !m.IsSet() {
+		return []byte("null"), nil
+	}
+	type plain Money
+	return json.Marshal(plain(m))
+}
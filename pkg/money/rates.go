@@ -0,0 +1,125 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+var ErrNoRate = errors.New("no exchange rate for the currency")
+
+// Rates converts between currencies through a base currency. It is read only once loaded.
+type Rates struct {
+	base  string
+	rates map[string]*big.Rat // units of the currency for one unit of the base currency
+}
+
+// ratesFile is the layout of the rate table, rates are decimal strings so they load exactly:
+//
+//	{"base": "USD", "rates": {"EUR": "0.92", "JPY": "151.30"}}
+type ratesFile struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// NewRates only knows the base currency, every other conversion fails with ErrNoRate
+func NewRates(base string) (*Rates, error) {
+	code, err := Normalize(base)
+	if err != nil {
+		return nil, errors.Wrap(err, base)
+	}
+	return &Rates{base: code, rates: map[string]*big.Rat{code: big.NewRat(1, 1)}}, nil
+}
+
+// LoadRates reads the rate table from a JSON file, without a file only currency is known.
+// currency is the default currency of the shop and must be covered by the table.
+func LoadRates(path string, currency string) (*Rates, error) {
+	if len(path) == 0 {
+		return NewRates(currency)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file ratesFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, errors.Wrap(err, "exchange rates file is not valid")
+	}
+	r, err := NewRates(file.Base)
+	if err != nil {
+		return nil, err
+	}
+	for code, value := range file.Rates {
+		c, err := Normalize(code)
+		if err != nil {
+			return nil, errors.Wrap(err, code)
+		}
+		rate, ok := new(big.Rat).SetString(value.String())
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("exchange rate of %s is not a positive number", code)
+		}
+		if c == r.base && rate.Cmp(big.NewRat(1, 1)) != 0 {
+			return nil, fmt.Errorf("exchange rate of the base currency %s must be 1", code)
+		}
+		r.rates[c] = rate
+	}
+	if !r.Supports(currency) {
+		return nil, errors.Wrapf(ErrNoRate, "default currency %s", currency)
+	}
+	return r, nil
+}
+
+func (r *Rates) Base() string {
+	return r.base
+}
+
+// Supports reports whether amounts can be converted to and from the currency
+func (r *Rates) Supports(currency string) bool {
+	code, err := Normalize(currency)
+	if err != nil {
+		return false
+	}
+	_, ok := r.rates[code]
+	return ok
+}
+
+// Factor is what one minor unit of from is worth in minor units of to, before rounding
+func (r *Rates) Factor(from string, to string) (*big.Rat, error) {
+	src, err := Lookup(from)
+	if err != nil {
+		return nil, errors.Wrap(err, from)
+	}
+	dst, err := Lookup(to)
+	if err != nil {
+		return nil, errors.Wrap(err, to)
+	}
+	fromRate, ok := r.rates[src.Code]
+	if !ok {
+		return nil, errors.Wrap(ErrNoRate, src.Code)
+	}
+	toRate, ok := r.rates[dst.Code]
+	if !ok {
+		return nil, errors.Wrap(ErrNoRate, dst.Code)
+	}
+	// minor units of from -> major units of from -> base -> major units of to -> minor units of to
+	f := new(big.Rat).Quo(toRate, fromRate)
+	f.Mul(f, new(big.Rat).SetFrac(dst.scale(), src.scale()))
+	return f, nil
+}
+
+// Convert exchanges the amount into the currency, rounded by that currency's rules
+func (r *Rates) Convert(m Money, to string) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	f, err := r.Factor(m.Currency, to)
+	if err != nil {
+		return Money{}, err
+	}
+	dst, _ := Lookup(to)
+	amount := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), f)
+	return Money{Amount: dst.Round(amount), Currency: dst.Code}, nil
+}
@@ -32,11 +32,12 @@ func (c notificationClient) SendSMS(phone string, message string) error {
 	params := &twilioApi.CreateMessageParams{}
 	params.SetTo(phone)
 	params.SetFrom(c.config.TwilioFromPhoneNumber)
-	params.SetBody("Hello from Go!")
+	params.SetBody(message)
 
 	resp, err := client.Api.CreateMessage(params)
 	if err != nil {
 		fmt.Println("Error sending SMS message: " + err.Error())
+		return err
 	} else {
 		response, _ := json.Marshal(*resp)
 		fmt.Println("Response: " + string(response))
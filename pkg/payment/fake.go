@@ -0,0 +1,214 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// test cards understood by the fake provider
+const (
+	TestCardSuccess     = "4242424242424242"
+	TestCardDeclined    = "4000000000000002"
+	TestCard3DSRequired = "4000002500003155"
+)
+
+// fakeProvider keeps intents in memory and settles them by card number, so checkout works offline.
+// Ids are sequential, which keeps runs reproducible.
+type fakeProvider struct {
+	mu      sync.Mutex
+	seq     int
+	intents map[string]*Intent
+	cards   map[string]string
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) nextId(prefix string) string {
+	p.seq++
+	return fmt.Sprintf("%s_fake_%06d", prefix, p.seq)
+}
+
+func (p *fakeProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	if params.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	capture := params.CaptureMethod
+	if len(capture) == 0 {
+		capture = CaptureAutomatic
+	}
+	intent := &Intent{
+		ID:            p.nextId("pi"),
+		Status:        StatusRequiresConfirmation,
+		Amount:        params.Amount,
+		Currency:      strings.ToLower(params.Currency),
+		CaptureMethod: capture,
+	}
+	p.intents[intent.ID] = intent
+	copied := *intent
+	return &copied, nil
+}
+
+// Confirm charges the test card: the success card is authorised, the 3DS card asks for authentication
+// first and every other number is declined
+func (p *fakeProvider) Confirm(intentId string, params ConfirmParams) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentId]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	card := strings.ReplaceAll(params.PaymentMethod, " ", "")
+	switch intent.Status {
+	case StatusRequiresConfirmation, StatusFailed:
+		p.cards[intentId] = card
+	case StatusRequiresAction:
+		if len(card) == 0 {
+			card = p.cards[intentId]
+		}
+	default:
+		return nil, ErrInvalidState
+	}
+
+	intent.NextAction = ""
+	intent.FailureMessage = ""
+	switch {
+	case card == TestCard3DSRequired && !params.Authenticated:
+		intent.Status = StatusRequiresAction
+		intent.NextAction = "three_d_secure"
+	case card == TestCardSuccess, card == TestCard3DSRequired:
+		p.authorise(intent)
+	case card == TestCardDeclined:
+		intent.Status = StatusFailed
+		intent.FailureMessage = "card declined"
+	default:
+		intent.Status = StatusFailed
+		intent.FailureMessage = "unknown test card"
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *fakeProvider) authorise(intent *Intent) {
+	if intent.CaptureMethod == CaptureManual {
+		intent.Status = StatusRequiresCapture
+		return
+	}
+	intent.Status = StatusSucceeded
+	intent.AmountCaptured = intent.Amount
+	intent.ChargeId = p.nextId("ch")
+}
+
+// Capture takes up to the authorised amount, 0 captures all of it
+func (p *fakeProvider) Capture(intentId string, amount int64) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentId]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status != StatusRequiresCapture {
+		return nil, ErrInvalidState
+	}
+	if amount == 0 {
+		amount = intent.Amount
+	}
+	if amount < 0 || amount > intent.Amount {
+		return nil, ErrInvalidAmount
+	}
+	intent.Status = StatusSucceeded
+	intent.AmountCaptured = amount
+	intent.ChargeId = p.nextId("ch")
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *fakeProvider) Cancel(intentId string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentId]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status == StatusSucceeded || intent.Status == StatusCanceled {
+		return nil, ErrInvalidState
+	}
+	intent.Status = StatusCanceled
+	intent.NextAction = ""
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *fakeProvider) Refund(intentId string, amount int64, reason string) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentId]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status != StatusSucceeded {
+		return nil, ErrInvalidState
+	}
+	left := intent.AmountCaptured - intent.AmountRefunded
+	if amount <= 0 || amount > left {
+		return nil, ErrInvalidAmount
+	}
+	intent.AmountRefunded += amount
+	return &Refund{
+		ID:       p.nextId("re"),
+		IntentId: intent.ID,
+		Amount:   amount,
+		Status:   StatusSucceeded,
+	}, nil
+}
+
+func (p *fakeProvider) FetchStatus(intentId string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentId]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+// NewFakeProvider returns an empty in-process gateway, intents do not survive a restart
+func NewFakeProvider() PaymentProvider {
+	return &fakeProvider{
+		intents: map[string]*Intent{},
+		cards:   map[string]string{},
+	}
+}
+
+// fakeEvent is the webhook payload of the fake provider: {"id": "evt_1", "type": "payment_intent.succeeded", "data": {"object": {...intent}}}
+type fakeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object *Intent `json:"object"`
+	} `json:"data"`
+}
+
+func (p *fakeProvider) ParseEvent(payload []byte) (*Event, error) {
+	var e fakeEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+	if len(e.ID) == 0 || e.Data.Object == nil || len(e.Data.Object.ID) == 0 {
+		return nil, errors.New("webhook event needs an id and an intent")
+	}
+	return &Event{ID: e.ID, Type: e.Type, Intent: e.Data.Object}, nil
+}
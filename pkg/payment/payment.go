@@ -0,0 +1,96 @@
+package payment
+
+import (
+	"ecommerce-app/config"
+	"errors"
+	"fmt"
+)
+
+const (
+	StatusRequiresConfirmation = "requires_confirmation"
+	StatusRequiresAction       = "requires_action" // the buyer has to authenticate the card, e.g. 3D Secure
+	StatusRequiresCapture      = "requires_capture"
+	StatusSucceeded            = "succeeded"
+	StatusFailed               = "failed"
+	StatusCanceled             = "canceled"
+)
+
+const (
+	CaptureAutomatic = "automatic"
+	CaptureManual    = "manual"
+)
+
+var (
+	ErrIntentNotFound = errors.New("payment intent does not exist")
+	ErrInvalidState   = errors.New("payment intent cannot do that in its current status")
+	ErrInvalidAmount  = errors.New("amount is not valid for the payment intent")
+)
+
+// IntentParams describes the payment to collect. Amounts throughout are in minor units of the currency, e.g. cents.
+type IntentParams struct {
+	Amount        int64
+	Currency      string
+	CaptureMethod string // automatic (default) or manual
+	CustomerId    string
+	Reference     string // shown on the provider dashboard, e.g. the order reference
+}
+
+// ConfirmParams attaches the buyer's payment method to the intent
+type ConfirmParams struct {
+	PaymentMethod string
+	Authenticated bool // the buyer completed the action the intent asked for
+}
+
+// Intent is the provider's view of one payment
+type Intent struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Amount         int64  `json:"amount"`
+	AmountCaptured int64  `json:"amount_captured"`
+	AmountRefunded int64  `json:"amount_refunded"`
+	Currency       string `json:"currency"`
+	CaptureMethod  string `json:"capture_method"`
+	ChargeId       string `json:"charge_id"`   // set once the money moved
+	NextAction     string `json:"next_action"` // what the buyer has to do when the status is requires_action
+	FailureMessage string `json:"failure_message"`
+}
+
+// Refund is money returned on a captured intent
+type Refund struct {
+	ID       string
+	IntentId string
+	Amount   int64
+	Status   string
+}
+
+// Event is a webhook notification about an intent, sent by the provider when its status changes
+type Event struct {
+	ID     string
+	Type   string
+	Intent *Intent
+}
+
+// PaymentProvider is a payment gateway, e.g. Stripe. Declined cards are not errors,
+// they come back as an intent in status failed with a failure message.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(params IntentParams) (*Intent, error)
+	Confirm(intentId string, params ConfirmParams) (*Intent, error)
+	Capture(intentId string, amount int64) (*Intent, error)
+	Cancel(intentId string) (*Intent, error)
+	Refund(intentId string, amount int64, reason string) (*Refund, error)
+	FetchStatus(intentId string) (*Intent, error)
+	// ParseEvent reads a webhook payload whose signature has already been verified
+	ParseEvent(payload []byte) (*Event, error)
+}
+
+// NewPaymentProvider returns the gateway selected by PAYMENT_PROVIDER. The in-process fake forgets its
+// intents on restart, so it is only used when selected as "fake".
+func NewPaymentProvider(config config.AppConfig) (PaymentProvider, error) {
+	switch config.PaymentProvider {
+	case "fake":
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("PAYMENT_PROVIDER %q is not supported", config.PaymentProvider)
+	}
+}
@@ -0,0 +1,67 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<payload>">"
+const SignatureHeader = "X-Payment-Signature"
+
+// how old a signed webhook may be, older ones are treated as replays
+const signatureTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("webhook signature is not valid")
+
+// Sign returns the signature header value for the payload, providers sign their webhooks this way
+func Sign(secret string, payload []byte, at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, signature(secret, ts, payload))
+}
+
+// VerifySignature checks the signature header against the payload and the shared secret
+func VerifySignature(secret string, header string, payload []byte, now time.Time) error {
+	if len(secret) == 0 {
+		return ErrInvalidSignature
+	}
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > signatureTolerance || age < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+	expected := signature(secret, ts, payload)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func signature(secret string, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
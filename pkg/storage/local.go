@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"errors"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+type localStorage struct {
+	root string
+}
+
+func (s localStorage) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", errors.New("invalid file key")
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s localStorage) Put(key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// write to a temporary file first so readers never see a partial file
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s localStorage) Get(key string) (*Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Object{
+		Body:        file,
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+		Size:        info.Size(),
+	}, nil
+}
+
+func (s localStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// NewLocalStorage stores files below the given directory
+func NewLocalStorage(root string) Storage {
+	if len(root) == 0 {
+		root = "uploads"
+	}
+	return &localStorage{root: root}
+}
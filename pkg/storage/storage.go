@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"ecommerce-app/config"
+	"errors"
+	"io"
+	"strings"
+)
+
+var ErrNotFound = errors.New("file does not exist")
+
+// Object is a stored file opened for reading, the caller must close Body
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// Storage keeps uploaded files under slash separated keys such as "products/12/a1b2.jpg"
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (*Object, error)
+	Delete(key string) error
+}
+
+// NewStorage returns the backend selected by STORAGE_DRIVER, the local filesystem by default
+func NewStorage(config config.AppConfig) Storage {
+	if config.StorageDriver == "s3" {
+		return NewS3Storage(config)
+	}
+	return NewLocalStorage(config.StorageLocalDir)
+}
+
+// validKey rejects keys that could escape the storage root
+func validKey(key string) bool {
+	if len(key) == 0 || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}